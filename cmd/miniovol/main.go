@@ -3,18 +3,30 @@ package main
 import (
 	"flag"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/golang/glog"
 
+	"github.com/docker/go-connections/sockets"
 	"github.com/docker/go-plugins-helpers/volume"
 
+	"github.com/cloudflavor/miniovol/pkg/admin"
 	"github.com/cloudflavor/miniovol/pkg/driver"
 )
 
 const (
-	socketAddress = "/run/docker/plugins/miniovol.sock"
-	rootID        = 0
+	socketAddress      = "/run/docker/plugins/miniovol.sock"
+	adminSocketAddress = "/run/docker/plugins/miniovol-admin.sock"
+	defaultConfigPath  = "/etc/miniovol/config.json"
+	defaultStatePath   = "/var/lib/miniovol/state.json"
+	defaultShutdown    = 30 * time.Second
+	rootID             = 0
 )
 
 func main() {
@@ -26,10 +38,151 @@ func main() {
 	}
 	flag.Parse()
 
+	cfgPath := os.Getenv("MINIOVOL_CONFIG")
+	if cfgPath == "" {
+		cfgPath = defaultConfigPath
+	}
+	cfg, err := driver.LoadConfig(cfgPath)
+	if err != nil {
+		log.Fatalf("An error occured while loading the config: %s", err)
+	}
+
+	shutdownTimeout := defaultShutdown
+	if timeout := os.Getenv("MINIOVOL_SHUTDOWN_TIMEOUT"); timeout != "" {
+		if shutdownTimeout, err = time.ParseDuration(timeout); err != nil {
+			log.Fatalf("Invalid MINIOVOL_SHUTDOWN_TIMEOUT: %s", err)
+		}
+	}
+	mountsPolicy := os.Getenv("MINIOVOL_SHUTDOWN_MOUNTS")
+	if mountsPolicy == "" {
+		mountsPolicy = driver.MountsKeep
+	}
+	if mountsPolicy != driver.MountsKeep && mountsPolicy != driver.MountsUnmount {
+		log.Fatalf("Invalid MINIOVOL_SHUTDOWN_MOUNTS %s, must be %s or %s", mountsPolicy, driver.MountsKeep, driver.MountsUnmount)
+	}
+	statePath := os.Getenv("MINIOVOL_STATE")
+	if statePath == "" {
+		statePath = defaultStatePath
+	}
+
 	d := driver.NewMinioDriver(nil, false)
-	h := volume.NewHandler(d)
-	glog.V(0).Infof("Trying to serve on %s", socketAddress)
-	if err := h.ServeUnix(socketAddress, rootID); err != nil {
+	d.SetConfig(cfg, cfgPath)
+	d.SetStateFile(statePath)
+	if err := d.LoadState(); err != nil {
+		log.Fatalf("An error occured while loading the state: %s", err)
+	}
+	if _, err := d.Reconcile(); err != nil {
+		glog.Errorf("An error occured while adopting the mounts: %s", err)
+	}
+	probe(d)
+	discover(d)
+	go reloadOnHangup(d)
+	stop := make(chan struct{})
+	go d.WatchSecretKeyFiles(driver.SecretKeyFileInterval, stop)
+	go d.WatchEndpoints(driver.EndpointCheckInterval, stop)
+	go d.AbortStaleUploads(driver.StaleUploadAge)
+
+	if err := os.MkdirAll(filepath.Dir(socketAddress), 0755); err != nil {
+		log.Fatalf("An error occured while creating the plugin socket dir: %s", err)
+	}
+	a := admin.NewHandler(d)
+	go func() {
+		glog.V(0).Infof("Serving admin API on %s", adminSocketAddress)
+		if err := a.ServeUnix(adminSocketAddress, rootID); err != nil && err != http.ErrServerClosed {
+			glog.Errorf("An error occured while serving the admin API: %s", err)
+		}
+	}()
+	if addr := os.Getenv("MINIOVOL_ADMIN_ADDR"); addr != "" {
+		go func() {
+			glog.V(0).Infof("Serving admin API on %s", addr)
+			if err := a.ServeTCP(addr, os.Getenv("MINIOVOL_ADMIN_TOKEN")); err != nil && err != http.ErrServerClosed {
+				glog.Errorf("An error occured while serving the admin API: %s", err)
+			}
+		}()
+	}
+
+	l, err := sockets.NewUnixSocket(socketAddress, rootID)
+	if err != nil {
+		log.Fatalf("An error occured while trying to serve: %s", err)
+	}
+	h := volume.NewHandler(d.Instrumented())
+	served := make(chan error, 1)
+	go func() {
+		glog.V(0).Infof("Trying to serve on %s", socketAddress)
+		served <- h.Serve(l)
+	}()
+
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-served:
 		log.Fatalf("An error occured while trying to serve: %s", err)
+	case sig := <-term:
+		glog.V(0).Infof("Received %s", sig)
+	}
+
+	// Closing the listeners stops new requests. The admin requests in flight
+	// are drained first, since they may create volumes, then the driver
+	// drains the docker ones with what is left of the timeout.
+	close(stop)
+	l.Close()
+	deadline := time.Now().Add(shutdownTimeout)
+	if err := a.Shutdown(shutdownTimeout); err != nil {
+		glog.Errorf("An error occured while shutting down the admin API: %s", err)
+	}
+	remaining := deadline.Sub(time.Now())
+	if remaining < 0 {
+		remaining = 0
+	}
+	if err := d.Shutdown(remaining, mountsPolicy); err != nil {
+		glog.Errorf("An error occured while shutting down: %s", err)
+		glog.Flush()
+		os.Exit(1)
+	}
+}
+
+// probe checks the backends of the driver at startup so that a bad endpoint,
+// bad credentials or a missing mount backend show up in the plugin logs
+// instead of the first time a volume is created.
+func probe(d *driver.MinioDriver) {
+	health := d.Health()
+	for _, check := range health.Checks {
+		if !check.Healthy {
+			glog.Errorf("Startup probe %s failed: %s", check.Name, check.Error)
+			continue
+		}
+		glog.V(1).Infof("Startup probe %s passed", check.Name)
+	}
+	if health.Healthy {
+		glog.V(0).Info("Startup probe passed")
+	}
+}
+
+// discover registers the buckets matching the autoDiscover settings of the
+// configuration as volumes, if it has any.
+func discover(d *driver.MinioDriver) {
+	if d.Config().AutoDiscover == nil {
+		return
+	}
+	volumes, err := d.Discover()
+	if err != nil {
+		glog.Errorf("An error occured while discovering volumes: %s", err)
+	}
+	if len(volumes) > 0 {
+		glog.V(0).Infof("Discovered volumes: %s", strings.Join(volumes, ", "))
+	}
+}
+
+// reloadOnHangup reloads the configuration of the driver every time the
+// plugin receives SIGHUP.
+func reloadOnHangup(d *driver.MinioDriver) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		glog.V(0).Info("Received SIGHUP, reloading config")
+		if _, err := d.Reload(); err == nil {
+			probe(d)
+			discover(d)
+		}
 	}
 }
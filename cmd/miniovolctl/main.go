@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudflavor/miniovol/pkg/admin"
+	pkgclient "github.com/cloudflavor/miniovol/pkg/client"
+	"github.com/cloudflavor/miniovol/pkg/driver"
+)
+
+const (
+	// defaultSocket is where the admin socket of the plugin shows up on the
+	// host when the plugin is enabled as a managed plugin.
+	defaultSocket = "/run/docker/plugins/miniovol-admin.sock"
+	usage         = `Usage: miniovolctl [-socket path | -addr host:port -token token] <command> [args]
+
+Commands:
+  ls                    list volumes with their mount state and mount IDs
+  inspect <volume>      show the full state of a volume
+  health                show the health of the plugin backends
+  unmount <volume>      force unmount a volume
+  remount <volume>      unmount and mount a volume again
+  release <volume> <id> release a stale mount ID of a volume
+  rotate <volume> [key] rotate the credentials of a volume, the secret key
+                        is read from stdin
+  expire <volume> <days> [prefix]
+                        expire the objects of a volume, or the ones under
+                        prefix, after days, 0 removes the expiration
+  adopt <volume> <bucket> [option=value...]
+                        register an existing bucket as a volume
+  discover              register the buckets matching autoDiscover
+  export <volume> <file> [prefix]
+                        export the bucket of a volume to a tar archive,
+                        gzipped when file ends with .gz or .tgz, - for stdout
+  import <volume> <file> [option=value...]
+                        import a tar archive into a volume, created with the
+                        options when it doesn't exist, - for stdin
+  snapshot <volume>     snapshot the bucket of a volume
+  snapshots <volume>    list the snapshots of a volume
+  reconcile             reconcile mount IDs with the mount table
+  config                dump the effective configuration
+  profiles              list the profiles of the configuration
+  reload                reload the configuration
+  metrics               show the request metrics of the plugin
+`
+)
+
+func main() {
+	socket := flag.String("socket", socketPath(), "path of the plugin admin socket")
+	addr := flag.String("addr", os.Getenv("MINIOVOL_ADMIN_ADDR"), "TCP address of the plugin admin API, used instead of the socket")
+	token := flag.String("token", os.Getenv("MINIOVOL_ADMIN_TOKEN"), "token for the TCP admin API")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	c := newClient(*socket, *addr, *token)
+	if err := run(c, flag.Args(), os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "miniovolctl: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func socketPath() string {
+	if socket := os.Getenv("MINIOVOL_ADMIN_SOCKET"); socket != "" {
+		return socket
+	}
+	return defaultSocket
+}
+
+func run(c *client, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return fmt.Errorf("no command given")
+	}
+
+	cmd, args := args[0], args[1:]
+	switch {
+	case cmd == "ls" && len(args) == 0:
+		var vols []driver.VolumeInfo
+		if err := c.do("GET", "volumes", &vols); err != nil {
+			return err
+		}
+		return printVolumes(out, vols)
+	case cmd == "inspect" && len(args) == 1:
+		return c.print(out, "GET", "volumes/"+args[0], &driver.VolumeInfo{})
+	case cmd == "health" && len(args) == 0:
+		return c.print(out, "GET", "health", &driver.Health{})
+	case cmd == "unmount" && len(args) == 1:
+		return c.print(out, "POST", "volumes/"+args[0]+"/unmount", &driver.VolumeInfo{})
+	case cmd == "remount" && len(args) == 1:
+		return c.print(out, "POST", "volumes/"+args[0]+"/remount", &driver.VolumeInfo{})
+	case cmd == "release" && len(args) == 2:
+		return c.print(out, "DELETE", "volumes/"+args[0]+"/mounts/"+args[1], &driver.VolumeInfo{})
+	case cmd == "rotate" && (len(args) == 1 || len(args) == 2):
+		secretKey, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		creds := admin.CredentialsRequest{SecretKey: strings.TrimSpace(string(secretKey))}
+		if len(args) == 2 {
+			creds.AccessKey = args[1]
+		}
+		return c.send(out, "PUT", "volumes/"+args[0]+"/credentials", creds, &driver.VolumeInfo{})
+	case cmd == "expire" && (len(args) == 2 || len(args) == 3):
+		days, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid number of days %s", args[1])
+		}
+		expiration := admin.ExpirationRequest{Days: days}
+		if len(args) == 3 {
+			expiration.Prefix = args[2]
+		}
+		return c.send(out, "PUT", "volumes/"+args[0]+"/expiration", expiration, &driver.VolumeInfo{})
+	case cmd == "adopt" && len(args) >= 2:
+		adopt := admin.AdoptRequest{Bucket: args[1], Options: make(map[string]string)}
+		for _, opt := range args[2:] {
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("invalid option %s, must be key=value", opt)
+			}
+			adopt.Options[kv[0]] = kv[1]
+		}
+		return c.send(out, "POST", "volumes/"+args[0]+"/adopt", adopt, &driver.VolumeInfo{})
+	case cmd == "discover" && len(args) == 0:
+		return c.print(out, "POST", "discover", &admin.DiscoverResponse{})
+	case cmd == "export" && (len(args) == 2 || len(args) == 3):
+		query := url.Values{}
+		if strings.HasSuffix(args[1], ".gz") || strings.HasSuffix(args[1], ".tgz") {
+			query.Set("compression", "gzip")
+		}
+		if len(args) == 3 {
+			query.Set("prefix", args[2])
+		}
+		return c.export(out, "volumes/"+args[0]+"/export?"+query.Encode(), args[1])
+	case cmd == "import" && len(args) >= 2:
+		options := make(map[string]string)
+		for _, opt := range args[2:] {
+			kv := strings.SplitN(opt, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("invalid option %s, must be key=value", opt)
+			}
+			options[kv[0]] = kv[1]
+		}
+		return c.importArchive(out, "volumes/"+args[0]+"/import", args[1], options)
+	case cmd == "snapshot" && len(args) == 1:
+		return c.print(out, "POST", "volumes/"+args[0]+"/snapshots", &driver.Snapshot{})
+	case cmd == "snapshots" && len(args) == 1:
+		return c.print(out, "GET", "volumes/"+args[0]+"/snapshots", &[]driver.Snapshot{})
+	case cmd == "reconcile" && len(args) == 0:
+		return c.print(out, "POST", "reconcile", &admin.ReconcileResponse{})
+	case cmd == "config" && len(args) == 0:
+		return c.print(out, "GET", "config", &driver.Config{})
+	case cmd == "profiles" && len(args) == 0:
+		return c.print(out, "GET", "profiles", &map[string]driver.Profile{})
+	case cmd == "reload" && len(args) == 0:
+		return c.print(out, "POST", "config/reload", &driver.Config{})
+	case cmd == "metrics" && len(args) == 0:
+		return c.print(out, "GET", "metrics", &driver.Metrics{})
+	}
+	flag.Usage()
+	return fmt.Errorf("invalid command: %s", strings.Join(append([]string{cmd}, args...), " "))
+}
+
+func printVolumes(out io.Writer, vols []driver.VolumeInfo) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tBUCKET\tMOUNTED\tMOUNT IDS")
+	for _, v := range vols {
+		ids := strings.Join(v.MountIDs, ",")
+		if ids == "" {
+			ids = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", v.Name, v.Bucket, v.Mounted, ids)
+	}
+	return w.Flush()
+}
+
+// client talks to the admin API of the plugin, either over its unix socket
+// or over TCP with a token.
+type client struct {
+	http  *http.Client
+	token string
+}
+
+func newClient(socket, addr, token string) *client {
+	network, address := "unix", socket
+	if addr != "" {
+		network, address = "tcp", addr
+	}
+	return &client{
+		http: &http.Client{
+			Transport: &http.Transport{
+				Dial: func(_, _ string) (net.Conn, error) {
+					return net.Dial(network, address)
+				},
+			},
+		},
+		token: token,
+	}
+}
+
+// do sends a request to the admin API and decodes the response into res.
+func (c *client) do(method, path string, res interface{}) error {
+	return c.doBody(method, path, nil, res)
+}
+
+// doBody sends a request with body encoded as JSON to the admin API and
+// decodes the response into res.
+func (c *client) doBody(method, path string, body, res interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	resp, err := c.request(method, path, "application/json", reqBody)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+// request sends a request with a body of contentType to the admin API and
+// returns its response, or the error returned by the API.
+func (c *client) request(method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, "http://miniovol/"+admin.APIVersion+"/"+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		errResp := admin.ErrorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error == "" {
+			return nil, fmt.Errorf("request failed: %s", resp.Status)
+		}
+		return nil, errors.New(errResp.Error)
+	}
+	return resp, nil
+}
+
+// export writes the archive returned by the admin API to file, or to out
+// when file is -. It fails when the archive lacks its manifest, which
+// happens when the export was cut short, and the file is then removed.
+func (c *client) export(out io.Writer, path, file string) error {
+	resp, err := c.request("GET", path, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if file == "-" {
+		return copyArchive(out, resp.Body)
+	}
+	fh, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = copyArchive(fh, resp.Body)
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(file)
+	}
+	return err
+}
+
+// copyArchive copies the archive read from r to w, checking that it ends
+// with its manifest.
+func copyArchive(w io.Writer, r io.Reader) error {
+	tee := io.TeeReader(r, w)
+	if _, err := pkgclient.ReadManifest(tee); err != nil {
+		return fmt.Errorf("incomplete export: %s", err)
+	}
+	_, err := io.Copy(ioutil.Discard, tee)
+	return err
+}
+
+// importArchive sends the archive found in file, or stdin when file is -,
+// along with the options of the volume to the admin API and prints the
+// manifest of the import. The archive is streamed as the archive part of a
+// multipart body, after the options part.
+func (c *client) importArchive(out io.Writer, path, file string, options map[string]string) error {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		fh, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		r = fh
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeImport(mw, r, options))
+	}()
+	resp, err := c.request("PUT", path, mw.FormDataContentType(), pr)
+	pr.Close()
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	manifest := pkgclient.Manifest{}
+	if err := json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return err
+	}
+	return printJSON(out, manifest)
+}
+
+// writeImport writes the options and the archive read from r as the parts
+// of an import request.
+func writeImport(mw *multipart.Writer, r io.Reader, options map[string]string) error {
+	part, err := mw.CreateFormField(admin.ImportOptionsPart)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(part).Encode(options); err != nil {
+		return err
+	}
+	if part, err = mw.CreateFormFile(admin.ImportArchivePart, "archive.tar"); err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// print sends a request to the admin API and prints the response as
+// indented JSON.
+func (c *client) print(out io.Writer, method, path string, res interface{}) error {
+	return c.send(out, method, path, nil, res)
+}
+
+// send sends a request with a body to the admin API and prints the response
+// as indented JSON.
+func (c *client) send(out io.Writer, method, path string, body, res interface{}) error {
+	if err := c.doBody(method, path, body, res); err != nil {
+		return err
+	}
+	return printJSON(out, res)
+}
+
+// printJSON prints res as indented JSON.
+func printJSON(out io.Writer, res interface{}) error {
+	data, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
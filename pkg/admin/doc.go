@@ -0,0 +1,3 @@
+// Package admin serves the management API of the plugin, which is used by
+// miniovolctl to inspect and manage the driver.
+package admin
@@ -0,0 +1,544 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/go-connections/sockets"
+	"github.com/golang/glog"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+	"github.com/cloudflavor/miniovol/pkg/driver"
+)
+
+// APIVersion is the version prefix of all the admin API paths.
+const APIVersion = "v1"
+
+// Driver is the part of the volume driver that is managed through the admin
+// API.
+type Driver interface {
+	Volumes() ([]driver.VolumeInfo, error)
+	Volume(name string) (driver.VolumeInfo, error)
+	ForceUnmount(name string) error
+	Remount(name string) error
+	ReleaseMount(name, id string) error
+	RotateCredentials(name, accessKey, secretKey string) error
+	SetExpiration(name string, days int, prefix string) error
+	Adopt(name, bucket string, options map[string]string) error
+	ExportVolume(name, prefix, compression string, w io.Writer) (client.Manifest, error)
+	ImportVolume(name string, r io.Reader, options map[string]string) (client.Manifest, error)
+	Reconcile() ([]string, error)
+	Discover() ([]string, error)
+	CreateSnapshot(name string) (driver.Snapshot, error)
+	Snapshots(name string) ([]driver.Snapshot, error)
+	Health() driver.Health
+	Config() driver.Config
+	Profiles() map[string]driver.Profile
+	Reload() (driver.Config, error)
+	Metrics() driver.Metrics
+}
+
+// ErrorResponse is the body returned by the admin API when a request fails.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// CredentialsRequest is the body of a credentials rotation request. An empty
+// access key keeps the current one.
+type CredentialsRequest struct {
+	AccessKey string `json:"accessKey,omitempty"`
+	SecretKey string `json:"secretKey"`
+}
+
+// ExpirationRequest is the body of a request that replaces the expiration
+// rule of a volume. Zero days removes the rule.
+type ExpirationRequest struct {
+	Days   int    `json:"days"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// AdoptRequest is the body of a request that adopts an existing bucket as a
+// volume. The options select the endpoint of the bucket, like the options of
+// docker volume create.
+type AdoptRequest struct {
+	Bucket  string            `json:"bucket"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// The parts of the multipart/form-data body of an import request. The
+// options part holds the options the volume is created with, as a JSON
+// object, and must come before the archive part.
+const (
+	ImportOptionsPart = "options"
+	ImportArchivePart = "archive"
+)
+
+// DiscoverResponse is the body returned by a discover request.
+type DiscoverResponse struct {
+	Volumes []string `json:"volumes"`
+}
+
+// ReconcileResponse is the body returned by a reconcile request.
+type ReconcileResponse struct {
+	Actions []string `json:"actions"`
+}
+
+// Handler serves the admin API for a driver.
+type Handler struct {
+	driver Driver
+	mux    *http.ServeMux
+
+	m sync.Mutex
+	// servers are the servers started by ServeUnix and ServeTCP, closed is
+	// set once Shutdown was called.
+	servers []*http.Server
+	closed  bool
+}
+
+// NewHandler initializes the admin API handler for a driver.
+func NewHandler(d Driver) *Handler {
+	h := &Handler{
+		driver: d,
+		mux:    http.NewServeMux(),
+	}
+	h.initMux()
+	return h
+}
+
+func (h *Handler) initMux() {
+	h.mux.HandleFunc(apiPath("volumes"), h.handleVolumes)
+	h.mux.HandleFunc(apiPath("volumes")+"/", h.handleVolume)
+	h.mux.HandleFunc(apiPath("reconcile"), h.handleReconcile)
+	h.mux.HandleFunc(apiPath("discover"), h.handleDiscover)
+	h.mux.HandleFunc(apiPath("health"), h.handleHealth)
+	h.mux.HandleFunc(apiPath("config"), h.handleConfig)
+	h.mux.HandleFunc(apiPath("config/reload"), h.handleReload)
+	h.mux.HandleFunc(apiPath("profiles"), h.handleProfiles)
+	h.mux.HandleFunc(apiPath("metrics"), h.handleMetrics)
+	h.mux.HandleFunc("/health", h.handleProbe)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	glog.V(1).Infof("Admin request: %s %s", r.Method, r.URL.Path)
+	h.mux.ServeHTTP(w, r)
+}
+
+// ServeUnix serves the admin API on a unix socket at addr.
+func (h *Handler) ServeUnix(addr string, gid int) error {
+	l, err := sockets.NewUnixSocket(addr, gid)
+	if err != nil {
+		return err
+	}
+	return h.serve(l, &http.Server{
+		Addr:    addr,
+		Handler: h,
+	})
+}
+
+// ServeTCP serves the admin API on a TCP address. Since anyone who can reach
+// the address can manage the plugin, clients have to present token.
+func (h *Handler) ServeTCP(addr, token string) error {
+	if token == "" {
+		return fmt.Errorf("a token is required to serve the admin API on %s", addr)
+	}
+	l, err := sockets.NewTCPSocket(addr, nil)
+	if err != nil {
+		return err
+	}
+	return h.serve(l, &http.Server{
+		Addr:    addr,
+		Handler: requireToken(token, h),
+	})
+}
+
+// serve serves the admin API with server on l until Shutdown is called, it
+// then returns http.ErrServerClosed.
+func (h *Handler) serve(l net.Listener, server *http.Server) error {
+	h.m.Lock()
+	if h.closed {
+		h.m.Unlock()
+		l.Close()
+		return http.ErrServerClosed
+	}
+	h.servers = append(h.servers, server)
+	h.m.Unlock()
+	return server.Serve(l)
+}
+
+// Shutdown stops serving the admin API and waits up to timeout for the
+// requests in flight to finish.
+func (h *Handler) Shutdown(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	h.closed = true
+	var errs []string
+	for _, server := range h.servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", server.Addr, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("admin API shutdown failed: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// requireToken only passes on the requests that carry token as their bearer
+// token.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		given := strings.TrimPrefix(auth, "Bearer ")
+		if !strings.HasPrefix(auth, "Bearer ") || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			encodeStatus(w, http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleVolumes serves GET /v1/volumes.
+func (h *Handler) handleVolumes(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	vols, err := h.driver.Volumes()
+	if err != nil {
+		encodeError(w, err)
+		return
+	}
+	encodeResponse(w, vols)
+}
+
+// handleVolume serves the requests for a single volume:
+//
+//	GET    /v1/volumes/{name}
+//	POST   /v1/volumes/{name}/unmount
+//	POST   /v1/volumes/{name}/remount
+//	DELETE /v1/volumes/{name}/mounts/{id}
+//	PUT    /v1/volumes/{name}/credentials
+//	PUT    /v1/volumes/{name}/expiration
+//	POST   /v1/volumes/{name}/adopt
+//	GET    /v1/volumes/{name}/export
+//	PUT    /v1/volumes/{name}/import
+//	GET    /v1/volumes/{name}/snapshots
+//	POST   /v1/volumes/{name}/snapshots
+func (h *Handler) handleVolume(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, apiPath("volumes")+"/"), "/")
+	name := parts[0]
+
+	switch {
+	case len(parts) == 1:
+		if !allowMethod(w, r, "GET") {
+			return
+		}
+		info, err := h.driver.Volume(name)
+		if err != nil {
+			encodeError(w, err)
+			return
+		}
+		encodeResponse(w, info)
+	case len(parts) == 2 && parts[1] == "unmount":
+		if !allowMethod(w, r, "POST") {
+			return
+		}
+		h.volumeAction(w, name, h.driver.ForceUnmount)
+	case len(parts) == 2 && parts[1] == "remount":
+		if !allowMethod(w, r, "POST") {
+			return
+		}
+		h.volumeAction(w, name, h.driver.Remount)
+	case len(parts) == 3 && parts[1] == "mounts":
+		if !allowMethod(w, r, "DELETE") {
+			return
+		}
+		h.volumeAction(w, name, func(name string) error {
+			return h.driver.ReleaseMount(name, parts[2])
+		})
+	case len(parts) == 2 && parts[1] == "credentials":
+		if !allowMethod(w, r, "PUT") {
+			return
+		}
+		creds := CredentialsRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+			encodeStatus(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid credentials: %s", err)})
+			return
+		}
+		h.volumeAction(w, name, func(name string) error {
+			return h.driver.RotateCredentials(name, creds.AccessKey, creds.SecretKey)
+		})
+	case len(parts) == 2 && parts[1] == "expiration":
+		if !allowMethod(w, r, "PUT") {
+			return
+		}
+		expiration := ExpirationRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&expiration); err != nil {
+			encodeStatus(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid expiration: %s", err)})
+			return
+		}
+		h.volumeAction(w, name, func(name string) error {
+			return h.driver.SetExpiration(name, expiration.Days, expiration.Prefix)
+		})
+	case len(parts) == 2 && parts[1] == "adopt":
+		if !allowMethod(w, r, "POST") {
+			return
+		}
+		adopt := AdoptRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&adopt); err != nil || adopt.Bucket == "" {
+			encodeStatus(w, http.StatusBadRequest, ErrorResponse{Error: "invalid adopt request: a bucket is required"})
+			return
+		}
+		h.volumeAction(w, name, func(name string) error {
+			return h.driver.Adopt(name, adopt.Bucket, adopt.Options)
+		})
+	case len(parts) == 2 && parts[1] == "export":
+		if !allowMethod(w, r, "GET") {
+			return
+		}
+		h.handleExport(w, r, name)
+	case len(parts) == 2 && parts[1] == "import":
+		if !allowMethod(w, r, "PUT") {
+			return
+		}
+		h.handleImport(w, r, name)
+	case len(parts) == 2 && parts[1] == "snapshots":
+		h.handleSnapshots(w, r, name)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleImport imports the archive part of a multipart/form-data request
+// into a volume. The options part, which may hold credentials and so isn't
+// passed in the URL, is optional. The archive is streamed to the volume as
+// it's received.
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request, name string) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		encodeStatus(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid import request: %s", err)})
+		return
+	}
+	options := make(map[string]string)
+	part, err := mr.NextPart()
+	if err == nil && part.FormName() == ImportOptionsPart {
+		if err := json.NewDecoder(part).Decode(&options); err != nil {
+			encodeStatus(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid import options: %s", err)})
+			return
+		}
+		part, err = mr.NextPart()
+	}
+	if err != nil || part.FormName() != ImportArchivePart {
+		encodeStatus(w, http.StatusBadRequest, ErrorResponse{Error: "invalid import request: an archive is required"})
+		return
+	}
+	manifest, err := h.driver.ImportVolume(name, part, options)
+	if err != nil {
+		encodeError(w, err)
+		return
+	}
+	encodeResponse(w, manifest)
+}
+
+// handleExport streams the archive of a volume. The prefix and compression
+// are passed as query parameters. Errors are answered as usual until the
+// archive starts, after which the connection is aborted so that the client
+// doesn't take the archive for a complete one.
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request, name string) {
+	compression := r.URL.Query().Get("compression")
+	aw := &archiveWriter{w: w, contentType: "application/x-tar"}
+	if compression == client.CompressionGzip {
+		aw.contentType = "application/gzip"
+	}
+	if _, err := h.driver.ExportVolume(name, r.URL.Query().Get("prefix"), compression, aw); err != nil {
+		if !aw.started {
+			encodeError(w, err)
+			return
+		}
+		glog.Warningf("Export of volume %s was cut short: %s", name, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// archiveWriter only sends the headers of an archive response along with
+// its first bytes, so that errors found before can still be answered.
+type archiveWriter struct {
+	w           http.ResponseWriter
+	contentType string
+	started     bool
+}
+
+func (a *archiveWriter) Write(p []byte) (int, error) {
+	if !a.started {
+		a.w.Header().Set("Content-Type", a.contentType)
+		a.w.WriteHeader(http.StatusOK)
+		a.started = true
+	}
+	return a.w.Write(p)
+}
+
+// handleSnapshots lists the snapshots of a volume or creates a new one.
+func (h *Handler) handleSnapshots(w http.ResponseWriter, r *http.Request, name string) {
+	switch r.Method {
+	case "GET":
+		snaps, err := h.driver.Snapshots(name)
+		if err != nil {
+			encodeError(w, err)
+			return
+		}
+		encodeResponse(w, snaps)
+	case "POST":
+		snap, err := h.driver.CreateSnapshot(name)
+		if err != nil {
+			encodeError(w, err)
+			return
+		}
+		encodeResponse(w, snap)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		encodeStatus(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+// volumeAction runs action on a volume and answers with the volume's state.
+func (h *Handler) volumeAction(w http.ResponseWriter, name string, action func(string) error) {
+	if err := action(name); err != nil {
+		encodeError(w, err)
+		return
+	}
+	info, err := h.driver.Volume(name)
+	if err != nil {
+		encodeError(w, err)
+		return
+	}
+	encodeResponse(w, info)
+}
+
+// handleReconcile serves POST /v1/reconcile.
+func (h *Handler) handleReconcile(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "POST") {
+		return
+	}
+	actions, err := h.driver.Reconcile()
+	if err != nil {
+		encodeError(w, err)
+		return
+	}
+	encodeResponse(w, ReconcileResponse{Actions: actions})
+}
+
+// handleDiscover serves POST /v1/discover.
+func (h *Handler) handleDiscover(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "POST") {
+		return
+	}
+	volumes, err := h.driver.Discover()
+	if err != nil {
+		encodeError(w, err)
+		return
+	}
+	encodeResponse(w, DiscoverResponse{Volumes: volumes})
+}
+
+// handleHealth serves GET /v1/health.
+func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	encodeResponse(w, h.driver.Health())
+}
+
+// handleProbe serves GET /health. Unlike /v1/health it responds with 503
+// when a check fails, so it can be used as is by health probes.
+func (h *Handler) handleProbe(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	health := h.driver.Health()
+	status := http.StatusOK
+	if !health.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	encodeStatus(w, status, health)
+}
+
+// handleConfig serves GET /v1/config.
+func (h *Handler) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	encodeResponse(w, h.driver.Config())
+}
+
+// handleProfiles serves GET /v1/profiles.
+func (h *Handler) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	encodeResponse(w, h.driver.Profiles())
+}
+
+// handleReload serves POST /v1/config/reload.
+func (h *Handler) handleReload(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "POST") {
+		return
+	}
+	cfg, err := h.driver.Reload()
+	if err != nil {
+		encodeError(w, err)
+		return
+	}
+	encodeResponse(w, cfg)
+}
+
+// handleMetrics serves GET /v1/metrics.
+func (h *Handler) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	encodeResponse(w, h.driver.Metrics())
+}
+
+func apiPath(path string) string {
+	return "/" + APIVersion + "/" + path
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		encodeStatus(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return false
+	}
+	return true
+}
+
+func encodeResponse(w http.ResponseWriter, res interface{}) {
+	encodeStatus(w, http.StatusOK, res)
+}
+
+func encodeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if _, ok := err.(driver.VolumeError); ok {
+		status = http.StatusNotFound
+	}
+	encodeStatus(w, status, ErrorResponse{Error: err.Error()})
+}
+
+func encodeStatus(w http.ResponseWriter, status int, res interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		glog.Warningf("Failed to encode admin response: %s", err)
+	}
+}
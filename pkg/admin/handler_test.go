@@ -0,0 +1,495 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+	"github.com/cloudflavor/miniovol/pkg/driver"
+)
+
+// fakeDriver records the calls made by the handler.
+type fakeDriver struct {
+	volumes   map[string]driver.VolumeInfo
+	released  []string
+	reloaded  bool
+	unhealthy bool
+	rotated   []string
+	expired   []string
+	adopted   []string
+}
+
+func newFakeDriver() *fakeDriver {
+	return &fakeDriver{
+		volumes: map[string]driver.VolumeInfo{
+			"test": {
+				Name:       "test",
+				Mountpoint: "/mnt/miniovol-test",
+				Bucket:     "miniobucket-test",
+				Mounted:    true,
+				MountIDs:   []string{"a", "b"},
+			},
+		},
+	}
+}
+
+func (f *fakeDriver) volume(name string) (driver.VolumeInfo, error) {
+	v, exists := f.volumes[name]
+	if !exists {
+		return v, driver.VolumeError{}
+	}
+	return v, nil
+}
+
+func (f *fakeDriver) Volumes() ([]driver.VolumeInfo, error) {
+	return []driver.VolumeInfo{f.volumes["test"]}, nil
+}
+
+func (f *fakeDriver) Volume(name string) (driver.VolumeInfo, error) {
+	return f.volume(name)
+}
+
+func (f *fakeDriver) ForceUnmount(name string) error {
+	_, err := f.volume(name)
+	return err
+}
+
+func (f *fakeDriver) Remount(name string) error {
+	return errors.New("mount failed")
+}
+
+func (f *fakeDriver) ReleaseMount(name, id string) error {
+	f.released = append(f.released, name+"/"+id)
+	return nil
+}
+
+func (f *fakeDriver) RotateCredentials(name, accessKey, secretKey string) error {
+	if _, err := f.volume(name); err != nil {
+		return err
+	}
+	f.rotated = append(f.rotated, name+"/"+accessKey+"/"+secretKey)
+	return nil
+}
+
+func (f *fakeDriver) SetExpiration(name string, days int, prefix string) error {
+	if _, err := f.volume(name); err != nil {
+		return err
+	}
+	f.expired = append(f.expired, fmt.Sprintf("%s/%d/%s", name, days, prefix))
+	return nil
+}
+
+func (f *fakeDriver) Adopt(name, bucket string, options map[string]string) error {
+	f.adopted = append(f.adopted, name+"/"+bucket+"/"+options["profile"])
+	f.volumes[name] = driver.VolumeInfo{Name: name, Bucket: bucket}
+	return nil
+}
+
+func (f *fakeDriver) ExportVolume(name, prefix, compression string, w io.Writer) (client.Manifest, error) {
+	if _, err := f.volume(name); err != nil {
+		return client.Manifest{}, err
+	}
+	if compression == client.CompressionZstd {
+		return client.Manifest{}, errors.New("zstd compression isn't available")
+	}
+	fmt.Fprintf(w, "archive of %s under %s", name, prefix)
+	if prefix == "broken" {
+		return client.Manifest{}, errors.New("failed to export broken/a")
+	}
+	return client.Manifest{}, nil
+}
+
+func (f *fakeDriver) ImportVolume(name string, r io.Reader, options map[string]string) (client.Manifest, error) {
+	data, _ := ioutil.ReadAll(r)
+	return client.Manifest{Bucket: name, Prefix: string(data) + "/" + options["profile"]}, nil
+}
+
+func (f *fakeDriver) Discover() ([]string, error) {
+	return []string{"adopted"}, nil
+}
+
+func (f *fakeDriver) Reconcile() ([]string, error) {
+	return []string{"mounted test"}, nil
+}
+
+func (f *fakeDriver) CreateSnapshot(name string) (driver.Snapshot, error) {
+	return driver.Snapshot{Bucket: "miniosnap-test"}, nil
+}
+
+func (f *fakeDriver) Snapshots(name string) ([]driver.Snapshot, error) {
+	return []driver.Snapshot{{Bucket: "miniosnap-test"}}, nil
+}
+
+func (f *fakeDriver) Health() driver.Health {
+	return driver.Health{Healthy: !f.unhealthy}
+}
+
+func (f *fakeDriver) Config() driver.Config {
+	return driver.Config{Server: "localhost:9000", SecretKey: "<redacted>"}
+}
+
+func (f *fakeDriver) Profiles() map[string]driver.Profile {
+	return map[string]driver.Profile{"archive": {Server: "archive:9000", SecretKey: "<redacted>"}}
+}
+
+func (f *fakeDriver) Reload() (driver.Config, error) {
+	f.reloaded = true
+	return f.Config(), nil
+}
+
+func (f *fakeDriver) Metrics() driver.Metrics {
+	return driver.Metrics{Volumes: 1}
+}
+
+func serve(h http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, nil)
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandleVolumes(t *testing.T) {
+	f := newFakeDriver()
+	w := serve(NewHandler(f), "GET", "/v1/volumes")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var vols []driver.VolumeInfo
+	if err := json.NewDecoder(w.Body).Decode(&vols); err != nil {
+		t.Fatalf("An error occured while decoding the volumes: %s", err)
+	}
+	if !reflect.DeepEqual(vols, []driver.VolumeInfo{f.volumes["test"]}) {
+		t.Errorf("Expected %#v, got %#v", f.volumes, vols)
+	}
+}
+
+func TestHandleVolumeNotFound(t *testing.T) {
+	w := serve(NewHandler(newFakeDriver()), "GET", "/v1/volumes/missing")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestHandleVolumeActions(t *testing.T) {
+	f := newFakeDriver()
+	h := NewHandler(f)
+
+	if w := serve(h, "POST", "/v1/volumes/test/unmount"); w.Code != http.StatusOK {
+		t.Errorf("Expected unmount status 200, got %d", w.Code)
+	}
+	if w := serve(h, "GET", "/v1/volumes/test/unmount"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected unmount with GET status 405, got %d", w.Code)
+	}
+
+	w := serve(h, "POST", "/v1/volumes/test/remount")
+	errResp := ErrorResponse{}
+	json.NewDecoder(w.Body).Decode(&errResp)
+	if w.Code != http.StatusInternalServerError || errResp.Error != "mount failed" {
+		t.Errorf("Expected remount to fail with status 500, got %d and %#v", w.Code, errResp)
+	}
+
+	if w := serve(h, "DELETE", "/v1/volumes/test/mounts/a"); w.Code != http.StatusOK {
+		t.Errorf("Expected release status 200, got %d", w.Code)
+	}
+	if !reflect.DeepEqual(f.released, []string{"test/a"}) {
+		t.Errorf("Expected mount a of test to be released, got %#v", f.released)
+	}
+
+	if w := serve(h, "POST", "/v1/volumes/test/unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected unknown action status 404, got %d", w.Code)
+	}
+}
+
+func TestHandleSnapshots(t *testing.T) {
+	h := NewHandler(newFakeDriver())
+
+	w := serve(h, "POST", "/v1/volumes/test/snapshots")
+	snap := driver.Snapshot{}
+	json.NewDecoder(w.Body).Decode(&snap)
+	if w.Code != http.StatusOK || snap.Bucket != "miniosnap-test" {
+		t.Errorf("Expected a new snapshot, got %d and %#v", w.Code, snap)
+	}
+
+	w = serve(h, "GET", "/v1/volumes/test/snapshots")
+	var snaps []driver.Snapshot
+	json.NewDecoder(w.Body).Decode(&snaps)
+	if w.Code != http.StatusOK || len(snaps) != 1 {
+		t.Errorf("Expected one snapshot, got %d and %#v", w.Code, snaps)
+	}
+
+	if w := serve(h, "DELETE", "/v1/volumes/test/snapshots"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected DELETE status 405, got %d", w.Code)
+	}
+}
+
+func TestHandleReload(t *testing.T) {
+	f := newFakeDriver()
+	h := NewHandler(f)
+
+	if w := serve(h, "GET", "/v1/config/reload"); w.Code != http.StatusMethodNotAllowed || f.reloaded {
+		t.Errorf("Expected GET not to reload, got %d", w.Code)
+	}
+	w := serve(h, "POST", "/v1/config/reload")
+	cfg := driver.Config{}
+	json.NewDecoder(w.Body).Decode(&cfg)
+	if w.Code != http.StatusOK || !f.reloaded || cfg.Server != "localhost:9000" {
+		t.Errorf("Expected config to be reloaded, got %d and %#v", w.Code, cfg)
+	}
+}
+
+func TestHandleProfiles(t *testing.T) {
+	h := NewHandler(newFakeDriver())
+
+	w := serve(h, "GET", "/v1/profiles")
+	var profiles map[string]driver.Profile
+	json.NewDecoder(w.Body).Decode(&profiles)
+	if w.Code != http.StatusOK || profiles["archive"].Server != "archive:9000" {
+		t.Errorf("Expected the archive profile, got %d and %#v", w.Code, profiles)
+	}
+	if w := serve(h, "POST", "/v1/profiles"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected POST status 405, got %d", w.Code)
+	}
+}
+
+func TestHandleHealthAndMetrics(t *testing.T) {
+	h := NewHandler(newFakeDriver())
+
+	w := serve(h, "GET", "/v1/health")
+	health := driver.Health{}
+	json.NewDecoder(w.Body).Decode(&health)
+	if w.Code != http.StatusOK || !health.Healthy {
+		t.Errorf("Expected healthy driver, got %d and %#v", w.Code, health)
+	}
+
+	w = serve(h, "GET", "/v1/metrics")
+	metrics := driver.Metrics{}
+	json.NewDecoder(w.Body).Decode(&metrics)
+	if w.Code != http.StatusOK || metrics.Volumes != 1 {
+		t.Errorf("Expected metrics with one volume, got %d and %#v", w.Code, metrics)
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	ts := httptest.NewServer(requireToken("secret", NewHandler(newFakeDriver())))
+	defer ts.Close()
+
+	tests := map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"secret":        http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	}
+	for auth, status := range tests {
+		req, _ := http.NewRequest("GET", ts.URL+"/v1/health", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("An error occured while sending the request: %s", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("Expected status %d for %q, got %d", status, auth, resp.StatusCode)
+		}
+	}
+}
+
+func TestServeTCPRequiresToken(t *testing.T) {
+	if err := NewHandler(newFakeDriver()).ServeTCP("127.0.0.1:0", ""); err == nil {
+		t.Errorf("Expected serving over TCP without a token to fail")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	dir, err := ioutil.TempDir("", "miniovol-admin-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	addr := filepath.Join(dir, "admin.sock")
+
+	h := NewHandler(newFakeDriver())
+	served := make(chan error, 1)
+	go func() {
+		served <- h.ServeUnix(addr, os.Getgid())
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(addr); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := h.Shutdown(time.Second); err != nil {
+		t.Fatalf("An error occured while shutting down: %s", err)
+	}
+	select {
+	case err := <-served:
+		if err != http.ErrServerClosed {
+			t.Errorf("Expected the admin API to be closed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the admin API to stop serving")
+	}
+	if err := h.ServeUnix(addr, os.Getgid()); err != http.ErrServerClosed {
+		t.Errorf("Expected the admin API not to be served after shutdown, got %v", err)
+	}
+}
+
+func TestHandleProbe(t *testing.T) {
+	f := newFakeDriver()
+	h := NewHandler(f)
+	if w := serve(h, "GET", "/health"); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	f.unhealthy = true
+	w := serve(h, "GET", "/health")
+	health := driver.Health{}
+	json.NewDecoder(w.Body).Decode(&health)
+	if w.Code != http.StatusServiceUnavailable || health.Healthy {
+		t.Errorf("Expected status 503 with unhealthy driver, got %d and %#v", w.Code, health)
+	}
+}
+
+func TestHandleCredentials(t *testing.T) {
+	f := newFakeDriver()
+	h := NewHandler(f)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/v1/volumes/test/credentials", strings.NewReader(`{"secretKey":"rotated"}`))
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !reflect.DeepEqual(f.rotated, []string{"test//rotated"}) {
+		t.Errorf("Expected credentials of test to be rotated, got %d and %v", w.Code, f.rotated)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/v1/volumes/test/credentials", strings.NewReader(`{`))
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid body, got %d", w.Code)
+	}
+
+	if w := serve(h, "POST", "/v1/volumes/test/credentials"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+func TestHandleExpiration(t *testing.T) {
+	f := newFakeDriver()
+	h := NewHandler(f)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/v1/volumes/test/expiration", strings.NewReader(`{"days":30,"prefix":"logs/"}`))
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !reflect.DeepEqual(f.expired, []string{"test/30/logs/"}) {
+		t.Errorf("Expected the expiration of test to be set, got %d and %v", w.Code, f.expired)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/v1/volumes/missing/expiration", strings.NewReader(`{"days":30}`))
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing volume, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/v1/volumes/test/expiration", strings.NewReader(`{"days":"many"}`))
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid body, got %d", w.Code)
+	}
+}
+
+func TestHandleAdopt(t *testing.T) {
+	f := newFakeDriver()
+	h := NewHandler(f)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/v1/volumes/data/adopt", strings.NewReader(`{"bucket":"existing","options":{"profile":"archive"}}`))
+	h.ServeHTTP(w, r)
+	info := driver.VolumeInfo{}
+	json.NewDecoder(w.Body).Decode(&info)
+	if w.Code != http.StatusOK || info.Bucket != "existing" || !reflect.DeepEqual(f.adopted, []string{"data/existing/archive"}) {
+		t.Errorf("Expected bucket existing to be adopted as data, got %d, %#v and %v", w.Code, info, f.adopted)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/v1/volumes/data/adopt", strings.NewReader(`{}`))
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a bucket, got %d", w.Code)
+	}
+
+	w = serve(h, "POST", "/v1/discover")
+	resp := DiscoverResponse{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || !reflect.DeepEqual(resp.Volumes, []string{"adopted"}) {
+		t.Errorf("Expected a discovered volume, got %d and %#v", w.Code, resp)
+	}
+}
+
+func TestHandleExportImport(t *testing.T) {
+	h := NewHandler(newFakeDriver())
+
+	w := serve(h, "GET", "/v1/volumes/test/export?prefix=data&compression=gzip")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/gzip" || w.Body.String() != "archive of test under data" {
+		t.Errorf("Expected the archive of test, got %d, %v and %q", w.Code, w.Header(), w.Body.String())
+	}
+	w = serve(h, "GET", "/v1/volumes/test/export?compression=zstd")
+	errResp := ErrorResponse{}
+	json.NewDecoder(w.Body).Decode(&errResp)
+	if w.Code != http.StatusInternalServerError || errResp.Error == "" {
+		t.Errorf("Expected the export to fail before the archive starts, got %d and %#v", w.Code, errResp)
+	}
+	if w := serve(h, "GET", "/v1/volumes/missing/export"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing volume, got %d", w.Code)
+	}
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/v1/volumes/test/export?prefix=broken")
+	if err == nil {
+		_, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if err == nil {
+		t.Errorf("Expected an export failing midway to be cut short")
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormField(ImportOptionsPart)
+	json.NewEncoder(part).Encode(map[string]string{"profile": "archive", "secretKey": "secret"})
+	part, _ = mw.CreateFormFile(ImportArchivePart, "archive.tar")
+	part.Write([]byte("tar"))
+	mw.Close()
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/v1/volumes/restored/import", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	h.ServeHTTP(w, r)
+	manifest := client.Manifest{}
+	json.NewDecoder(w.Body).Decode(&manifest)
+	if w.Code != http.StatusOK || manifest.Bucket != "restored" || manifest.Prefix != "tar/archive" {
+		t.Errorf("Expected the archive to be imported into restored, got %d and %#v", w.Code, manifest)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("PUT", "/v1/volumes/restored/import?profile=archive", strings.NewReader("tar"))
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an import without a multipart body to be refused, got %d", w.Code)
+	}
+}
@@ -0,0 +1,317 @@
+package client
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestName is the name of the tar entry that holds the manifest of an
+// export. It's the last entry of the archive.
+const ManifestName = ".miniovol-manifest.json"
+
+// Compression formats of the exports.
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// PAX records used to keep what tar headers have no room for. The manifest
+// entry is marked so that an object named like it is imported as an object.
+const (
+	paxManifest    = "MINIOVOL.manifest"
+	paxContentType = "MINIOVOL.content-type"
+	paxMetaPrefix  = "MINIOVOL.meta."
+)
+
+// exportedHeaders are the object headers preserved by an export, besides
+// the content type and the user metadata.
+var exportedHeaders = []string{"Cache-Control", "Content-Disposition", "Content-Encoding", "Content-Language"}
+
+// Manifest lists the objects of an export along with their checksums.
+type Manifest struct {
+	Bucket  string          `json:"bucket"`
+	Prefix  string          `json:"prefix,omitempty"`
+	Created time.Time       `json:"created"`
+	Objects []ManifestEntry `json:"objects"`
+}
+
+// ManifestEntry is an object of an export.
+type ManifestEntry struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// Export writes the objects of the bucket of the client found under prefix
+// to w as a tar archive, compressed according to compression, followed by
+// its manifest. The prefix is stripped from the names of the entries. The
+// content type and metadata of the objects are kept as PAX records.
+func (c *MinioClient) Export(w io.Writer, prefix, compression string) (Manifest, error) {
+	manifest := Manifest{Bucket: c.BucketName, Prefix: prefix, Created: time.Now().UTC()}
+	if c.BucketName == "" {
+		return manifest, fmt.Errorf("no bucket set for export")
+	}
+	if c.Encryption != nil && c.Encryption.Mode == SSEC {
+		return manifest, fmt.Errorf("objects encrypted with SSE-C can't be exported")
+	}
+	var gw *gzip.Writer
+	switch compression {
+	case CompressionNone:
+	case CompressionGzip:
+		gw = gzip.NewWriter(w)
+		w = gw
+	case CompressionZstd:
+		return manifest, fmt.Errorf("zstd compression isn't available, use %s", CompressionGzip)
+	default:
+		return manifest, fmt.Errorf("unknown compression %s", compression)
+	}
+
+	tw := tar.NewWriter(w)
+	prefix = normalizePrefix(prefix)
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	for obj := range c.Client.ListObjectsV2(c.BucketName, prefix, true, doneCh) {
+		if obj.Err != nil {
+			return manifest, obj.Err
+		}
+		name := copyTarget(prefix, obj.Key)
+		if name == "" || strings.HasSuffix(name, "/") {
+			continue
+		}
+		entry, err := c.exportObject(tw, obj.Key, name)
+		if err != nil {
+			return manifest, fmt.Errorf("failed to export %s: %s", obj.Key, err)
+		}
+		manifest.Objects = append(manifest.Objects, entry)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:       ManifestName,
+		Mode:       0644,
+		Size:       int64(len(data)),
+		ModTime:    manifest.Created,
+		PAXRecords: map[string]string{paxManifest: "1"},
+	}); err != nil {
+		return manifest, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return manifest, err
+	}
+	if err := tw.Close(); err != nil {
+		return manifest, err
+	}
+	if gw != nil {
+		return manifest, gw.Close()
+	}
+	return manifest, nil
+}
+
+// exportObject writes the object key to tw as the entry name.
+func (c *MinioClient) exportObject(tw *tar.Writer, key, name string) (ManifestEntry, error) {
+	obj, err := c.Client.GetObject(c.BucketName, key)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	defer obj.Close()
+	info, err := obj.Stat()
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+
+	records := map[string]string{}
+	if info.ContentType != "" {
+		records[paxContentType] = info.ContentType
+	}
+	for header, values := range info.Metadata {
+		if preservedHeader(header) && len(values) > 0 {
+			records[paxMetaPrefix+header] = values[0]
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:       name,
+		Mode:       0644,
+		Size:       info.Size,
+		ModTime:    info.LastModified,
+		PAXRecords: records,
+	}); err != nil {
+		return ManifestEntry{}, err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tw, h), obj); err != nil {
+		return ManifestEntry{}, err
+	}
+	return ManifestEntry{
+		Name:        name,
+		Size:        info.Size,
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+		ContentType: info.ContentType,
+	}, nil
+}
+
+// preservedHeader reports whether an object header is kept by exports.
+func preservedHeader(header string) bool {
+	header = http.CanonicalHeaderKey(header)
+	if strings.HasPrefix(header, "X-Amz-Meta-") {
+		return true
+	}
+	for _, h := range exportedHeaders {
+		if header == h {
+			return true
+		}
+	}
+	return false
+}
+
+// Import uploads the entries of a tar archive, gzipped or not, to the bucket
+// of the client, with the content type and metadata kept by Export. It
+// returns the manifest of the uploaded objects. When the archive has a
+// manifest, the objects are checked against it once they are all uploaded.
+// Buckets encrypted with SSE-C are refused, minio-go doesn't send the
+// customer key with the parts of large objects.
+func (c *MinioClient) Import(r io.Reader) (Manifest, error) {
+	manifest := Manifest{Bucket: c.BucketName, Created: time.Now().UTC()}
+	if c.BucketName == "" {
+		return manifest, fmt.Errorf("no destination bucket set for import")
+	}
+	if c.Encryption != nil && c.Encryption.Mode == SSEC {
+		return manifest, fmt.Errorf("buckets encrypted with SSE-C can't be imported into")
+	}
+	tr, closer, err := openTar(r)
+	if err != nil {
+		return manifest, err
+	}
+	defer closer.Close()
+
+	var expected *Manifest
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if hdr.Name == ManifestName && hdr.PAXRecords[paxManifest] != "" {
+			expected = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(expected); err != nil {
+				return manifest, fmt.Errorf("invalid manifest: %s", err)
+			}
+			continue
+		}
+		name := entryName(hdr.Name)
+		if name == "" {
+			continue
+		}
+		entry, err := c.importObject(tr, hdr, name)
+		if err != nil {
+			return manifest, fmt.Errorf("failed to import %s: %s", name, err)
+		}
+		manifest.Objects = append(manifest.Objects, entry)
+	}
+	if expected == nil {
+		return manifest, nil
+	}
+	manifest.Prefix = expected.Prefix
+	return manifest, verifyManifest(*expected, manifest)
+}
+
+// ReadManifest reads an archive written by Export to its end and returns its
+// manifest. An archive without a manifest was cut short, which is an error.
+func ReadManifest(r io.Reader) (Manifest, error) {
+	manifest := Manifest{}
+	tr, closer, err := openTar(r)
+	if err != nil {
+		return manifest, err
+	}
+	defer closer.Close()
+
+	found := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, err
+		}
+		if hdr.Name == ManifestName && hdr.PAXRecords[paxManifest] != "" {
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return manifest, fmt.Errorf("invalid manifest: %s", err)
+			}
+			found = true
+		}
+	}
+	if !found {
+		return manifest, fmt.Errorf("the archive has no manifest")
+	}
+	return manifest, nil
+}
+
+// importObject uploads the current entry of tr as the object name.
+func (c *MinioClient) importObject(tr *tar.Reader, hdr *tar.Header, name string) (ManifestEntry, error) {
+	metaData := map[string][]string(c.Encryption.Header())
+	ctype := hdr.PAXRecords[paxContentType]
+	if ctype == "" {
+		ctype = contentType(name)
+	}
+	metaData["Content-Type"] = []string{ctype}
+	for key, value := range hdr.PAXRecords {
+		if header := strings.TrimPrefix(key, paxMetaPrefix); header != key && preservedHeader(header) {
+			metaData[http.CanonicalHeaderKey(header)] = []string{value}
+		}
+	}
+
+	h := sha256.New()
+	body := sizedReader{io.TeeReader(tr, h), hdr.Size}
+	if _, err := c.Client.PutObjectWithMetadata(c.BucketName, name, body, metaData, nil); err != nil {
+		return ManifestEntry{}, err
+	}
+	return ManifestEntry{
+		Name:        name,
+		Size:        hdr.Size,
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+		ContentType: ctype,
+	}, nil
+}
+
+// verifyManifest checks that the imported objects match the manifest of the
+// archive they come from.
+func verifyManifest(expected, imported Manifest) error {
+	found := make(map[string]ManifestEntry, len(imported.Objects))
+	for _, entry := range imported.Objects {
+		found[entry.Name] = entry
+	}
+	var problems []string
+	for _, entry := range expected.Objects {
+		got, ok := found[entry.Name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s is missing", entry.Name))
+		case got.Size != entry.Size || got.SHA256 != entry.SHA256:
+			problems = append(problems, fmt.Sprintf("%s doesn't match its checksum", entry.Name))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("import doesn't match its manifest: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
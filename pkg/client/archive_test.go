@@ -0,0 +1,166 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeObject struct {
+	data   []byte
+	header http.Header
+}
+
+// fakeS3 serves the objects of the bucket testbucket from memory.
+type fakeS3 struct {
+	m       sync.Mutex
+	objects map[string]fakeObject
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/testbucket"), "/")
+	query := r.URL.Query()
+	switch {
+	case key == "" && r.Method == "GET":
+		f.list(w, query.Get("prefix"))
+	case r.Method == "PUT":
+		data, _ := ioutil.ReadAll(r.Body)
+		header := http.Header{}
+		for k, v := range r.Header {
+			if preservedHeader(k) || k == "Content-Type" {
+				header[k] = v
+			}
+		}
+		sum := md5.Sum(data)
+		header.Set("Etag", `"`+hex.EncodeToString(sum[:])+`"`)
+		f.objects[key] = fakeObject{data: data, header: header}
+		w.Header().Set("Etag", header.Get("Etag"))
+	case r.Method == "DELETE":
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "GET" || r.Method == "HEAD":
+		obj, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+		for k, v := range obj.header {
+			w.Header()[k] = v
+		}
+		http.ServeContent(w, r, key, time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), bytes.NewReader(obj.data))
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	fmt.Fprint(w, `<ListBucketResult><Name>testbucket</Name><IsTruncated>false</IsTruncated>`)
+	for _, key := range keys {
+		fmt.Fprintf(w, `<Contents><Key>%s</Key><Size>%d</Size><LastModified>2017-01-01T00:00:00.000Z</LastModified><ETag>%s</ETag></Contents>`,
+			key, len(f.objects[key].data), f.objects[key].header.Get("Etag"))
+	}
+	fmt.Fprint(w, `</ListBucketResult>`)
+}
+
+func newFakeS3Client(t *testing.T) (*MinioClient, *fakeS3, func()) {
+	f := &fakeS3{objects: make(map[string]fakeObject)}
+	c, ts := newTestClient(t, f.ServeHTTP)
+	return c, f, ts.Close
+}
+
+func TestExportImport(t *testing.T) {
+	src, srcS3, closeSrc := newFakeS3Client(t)
+	defer closeSrc()
+	srcS3.objects["data/report.csv"] = fakeObject{
+		data:   []byte("a,b\n1,2\n"),
+		header: http.Header{"Content-Type": {"text/csv"}, "X-Amz-Meta-Owner": {"ml"}},
+	}
+	srcS3.objects["data/models/weights.bin"] = fakeObject{
+		data:   bytes.Repeat([]byte{1, 2, 3}, 1000),
+		header: http.Header{"Content-Type": {"application/octet-stream"}},
+	}
+	srcS3.objects["other/skipped.txt"] = fakeObject{data: []byte("skipped")}
+
+	var archive bytes.Buffer
+	exported, err := src.Export(&archive, "data", CompressionGzip)
+	if err != nil {
+		t.Fatalf("An error occured while exporting: %s", err)
+	}
+	if len(exported.Objects) != 2 || exported.Objects[0].Name != "models/weights.bin" || exported.Objects[1].ContentType != "text/csv" {
+		t.Errorf("Expected the two objects under data in the manifest, got %#v", exported.Objects)
+	}
+	if manifest, err := ReadManifest(bytes.NewReader(archive.Bytes())); err != nil || len(manifest.Objects) != 2 {
+		t.Errorf("Expected the manifest of the archive, got %#v and %v", manifest, err)
+	}
+	if _, err := ReadManifest(bytes.NewReader(archive.Bytes()[:archive.Len()/2])); err == nil {
+		t.Errorf("Expected a truncated archive to be refused")
+	}
+
+	dst, dstS3, closeDst := newFakeS3Client(t)
+	defer closeDst()
+	imported, err := dst.Import(&archive)
+	if err != nil {
+		t.Fatalf("An error occured while importing: %s", err)
+	}
+	if len(imported.Objects) != 2 || imported.Prefix != "data" {
+		t.Errorf("Expected two imported objects from prefix data, got %#v", imported)
+	}
+	report, ok := dstS3.objects["report.csv"]
+	if !ok || string(report.data) != "a,b\n1,2\n" {
+		t.Fatalf("Expected report.csv to be imported, got %#v", dstS3.objects)
+	}
+	if report.header.Get("Content-Type") != "text/csv" || report.header.Get("X-Amz-Meta-Owner") != "ml" {
+		t.Errorf("Expected the content type and metadata to be kept, got %v", report.header)
+	}
+
+	if _, err := src.Export(ioutil.Discard, "", CompressionZstd); err == nil {
+		t.Errorf("Expected zstd compression to be refused")
+	}
+}
+
+func TestImportManifestMismatch(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	tw.WriteHeader(&tar.Header{Name: "a.txt", Mode: 0644, Size: 1})
+	tw.Write([]byte("a"))
+	manifest, _ := json.Marshal(Manifest{Objects: []ManifestEntry{
+		{Name: "a.txt", Size: 1, SHA256: "0000"},
+		{Name: "b.txt", Size: 1, SHA256: "0000"},
+	}})
+	tw.WriteHeader(&tar.Header{
+		Name:       ManifestName,
+		Mode:       0644,
+		Size:       int64(len(manifest)),
+		PAXRecords: map[string]string{paxManifest: "1"},
+	})
+	tw.Write(manifest)
+	tw.Close()
+
+	dst, _, closeDst := newFakeS3Client(t)
+	defer closeDst()
+	_, err := dst.Import(&archive)
+	if err == nil || !strings.Contains(err.Error(), "a.txt doesn't match its checksum") || !strings.Contains(err.Error(), "b.txt is missing") {
+		t.Errorf("Expected the import not to match its manifest, got %v", err)
+	}
+}
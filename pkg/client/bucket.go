@@ -0,0 +1,254 @@
+package client
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// expirationRuleID is the ID of the lifecycle rule managed by the plugin.
+const expirationRuleID = "miniovol-expiration"
+
+type lifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration"`
+	Rules   []lifecycleRule `xml:"Rule"`
+}
+
+// lifecycleRule is a rule of a lifecycle configuration. Raw holds the rule as
+// the server returned it, the rules not managed by the plugin are sent back
+// that way.
+type lifecycleRule struct {
+	ID         string               `xml:"ID,omitempty"`
+	Status     string               `xml:"Status,omitempty"`
+	Filter     *lifecycleFilter     `xml:",omitempty"`
+	Expiration *lifecycleExpiration `xml:",omitempty"`
+	Raw        string               `xml:",innerxml"`
+}
+
+type lifecycleFilter struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type lifecycleExpiration struct {
+	Days int `xml:"Days"`
+}
+
+// SetBucketExpiration installs a lifecycle rule on bucket that expires the
+// objects under prefix after the given number of days. It replaces the rule
+// previously installed by the plugin, the other rules of the bucket are kept.
+func (c *MinioClient) SetBucketExpiration(bucket string, days int, prefix string) error {
+	return c.updateExpiration(bucket, &lifecycleRule{
+		ID:         expirationRuleID,
+		Status:     "Enabled",
+		Filter:     &lifecycleFilter{Prefix: prefix},
+		Expiration: &lifecycleExpiration{Days: days},
+	})
+}
+
+// RemoveBucketExpiration removes the lifecycle rule installed by
+// SetBucketExpiration from bucket, the other rules of the bucket are kept.
+func (c *MinioClient) RemoveBucketExpiration(bucket string) error {
+	return c.updateExpiration(bucket, nil)
+}
+
+// updateExpiration replaces the rule of the plugin in the lifecycle
+// configuration of bucket with rule, or removes it when rule is nil. The
+// configuration is removed once it has no rules left.
+func (c *MinioClient) updateExpiration(bucket string, rule *lifecycleRule) error {
+	current := lifecycleConfiguration{}
+	data, err := c.bucketRequest("GET", bucket, "", subResource("lifecycle"), nil, nil)
+	if reqErr, ok := err.(RequestError); ok && reqErr.StatusCode == http.StatusNotFound {
+		err = nil
+	} else if err == nil {
+		err = xml.Unmarshal(data, &current)
+	}
+	if err != nil {
+		return err
+	}
+
+	cfg := lifecycleConfiguration{}
+	for _, r := range current.Rules {
+		if r.ID != expirationRuleID {
+			cfg.Rules = append(cfg.Rules, lifecycleRule{Raw: r.Raw})
+		}
+	}
+	if rule != nil {
+		cfg.Rules = append(cfg.Rules, *rule)
+	}
+	if len(cfg.Rules) == 0 {
+		if len(current.Rules) == 0 {
+			return nil
+		}
+		_, err := c.bucketRequest("DELETE", bucket, "", subResource("lifecycle"), nil, nil)
+		return err
+	}
+	body, err := xml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = c.bucketRequest("PUT", bucket, "", subResource("lifecycle"), nil, body)
+	return err
+}
+
+type createBucketConfiguration struct {
+	XMLName  xml.Name `xml:"CreateBucketConfiguration"`
+	Location string   `xml:"LocationConstraint"`
+}
+
+type versioningConfiguration struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+type objectLockConfiguration struct {
+	XMLName           xml.Name        `xml:"ObjectLockConfiguration"`
+	ObjectLockEnabled string          `xml:"ObjectLockEnabled"`
+	Rule              *objectLockRule `xml:"Rule,omitempty"`
+}
+
+type objectLockRule struct {
+	DefaultRetention struct {
+		Mode string `xml:"Mode"`
+		Days int    `xml:"Days"`
+	}
+}
+
+// MakeLockedBucket creates bucket in location with object lock enabled.
+// Object lock can only be enabled when a bucket is created and it also
+// enables versioning.
+func (c *MinioClient) MakeLockedBucket(bucket, location string) error {
+	header := http.Header{}
+	header.Set("X-Amz-Bucket-Object-Lock-Enabled", "true")
+	var body []byte
+	if location != "" && location != defaultRegion {
+		var err error
+		body, err = xml.Marshal(createBucketConfiguration{Location: location})
+		if err != nil {
+			return err
+		}
+	}
+	// The bucket doesn't exist yet, so its location can't be looked up.
+	region := location
+	if region == "" {
+		region = c.region()
+	}
+	host, path := c.bucketPath(bucket, "")
+	_, err := c.sendRequest("PUT", host, path, region, nil, header, body)
+	return err
+}
+
+// SetBucketVersioning sets the versioning status of bucket, either Enabled or
+// Suspended.
+func (c *MinioClient) SetBucketVersioning(bucket, status string) error {
+	body, err := xml.Marshal(versioningConfiguration{Status: status})
+	if err != nil {
+		return err
+	}
+	_, err = c.bucketRequest("PUT", bucket, "", subResource("versioning"), nil, body)
+	return err
+}
+
+// BucketVersioning returns the versioning status of bucket, which is empty if
+// versioning was never enabled.
+func (c *MinioClient) BucketVersioning(bucket string) (string, error) {
+	data, err := c.bucketRequest("GET", bucket, "", subResource("versioning"), nil, nil)
+	if err != nil {
+		return "", err
+	}
+	cfg := versioningConfiguration{}
+	if err := xml.Unmarshal(data, &cfg); err != nil {
+		return "", err
+	}
+	return cfg.Status, nil
+}
+
+// SetBucketRetention sets the default retention mode, GOVERNANCE or
+// COMPLIANCE, and period of a bucket created with MakeLockedBucket.
+func (c *MinioClient) SetBucketRetention(bucket, mode string, days int) error {
+	rule := &objectLockRule{}
+	rule.DefaultRetention.Mode = strings.ToUpper(mode)
+	rule.DefaultRetention.Days = days
+	body, err := xml.Marshal(objectLockConfiguration{
+		ObjectLockEnabled: "Enabled",
+		Rule:              rule,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = c.bucketRequest("PUT", bucket, "", subResource("object-lock"), nil, body)
+	return err
+}
+
+// BucketRetention returns the default retention mode and period of bucket.
+// The mode is empty if object lock isn't enabled on the bucket.
+func (c *MinioClient) BucketRetention(bucket string) (string, int, error) {
+	data, err := c.bucketRequest("GET", bucket, "", subResource("object-lock"), nil, nil)
+	if reqErr, ok := err.(RequestError); ok && reqErr.StatusCode == http.StatusNotFound {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	cfg := objectLockConfiguration{}
+	if err := xml.Unmarshal(data, &cfg); err != nil {
+		return "", 0, err
+	}
+	if cfg.Rule == nil {
+		return "", 0, nil
+	}
+	return cfg.Rule.DefaultRetention.Mode, cfg.Rule.DefaultRetention.Days, nil
+}
+
+type tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	TagSet  []tag    `xml:"TagSet>Tag"`
+}
+
+type tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// BucketTags returns the tags of bucket, which are empty if it has none.
+func (c *MinioClient) BucketTags(bucket string) (map[string]string, error) {
+	data, err := c.bucketRequest("GET", bucket, "", subResource("tagging"), nil, nil)
+	if reqErr, ok := err.(RequestError); ok && reqErr.StatusCode == http.StatusNotFound {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg := tagging{}
+	if err := xml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(cfg.TagSet))
+	for _, t := range cfg.TagSet {
+		tags[t.Key] = t.Value
+	}
+	return tags, nil
+}
+
+// PurgeBucket removes all the objects of bucket, then the bucket itself.
+func (c *MinioClient) PurgeBucket(bucket string) error {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	for obj := range c.Client.ListObjectsV2(bucket, "", true, doneCh) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		if err := c.Client.RemoveObject(bucket, obj.Key); err != nil {
+			return err
+		}
+	}
+	return c.Client.RemoveBucket(bucket)
+}
+
+// subResource returns the query used to address a bucket sub-resource.
+func subResource(name string) url.Values {
+	query := url.Values{}
+	query.Set(name, "")
+	return query
+}
@@ -0,0 +1,165 @@
+package client
+
+import (
+	"encoding/xml"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSetBucketExpiration(t *testing.T) {
+	other := `<ID>archive</ID><Status>Enabled</Status><Filter><Prefix>old/</Prefix></Filter>` +
+		`<Transition><Days>30</Days><StorageClass>GLACIER</StorageClass></Transition>`
+	var put *lifecycleConfiguration
+	c, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["lifecycle"]; r.URL.Path != "/testbucket" || !ok {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		switch r.Method {
+		case "GET":
+			w.Write([]byte(`<LifecycleConfiguration><Rule>` + other + `</Rule>` +
+				`<Rule><ID>miniovol-expiration</ID><Status>Enabled</Status><Filter><Prefix></Prefix></Filter>` +
+				`<Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`))
+		case "PUT":
+			if r.Header.Get("Content-Md5") == "" {
+				t.Errorf("Expected Content-Md5 header to be set")
+			}
+			put = &lifecycleConfiguration{}
+			if err := xml.NewDecoder(r.Body).Decode(put); err != nil {
+				t.Fatalf("An error occured while decoding the lifecycle: %s", err)
+			}
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer ts.Close()
+
+	if err := c.SetBucketExpiration("testbucket", 7, "tmp/"); err != nil {
+		t.Fatalf("An error occured while setting the expiration: %s", err)
+	}
+	if put == nil || len(put.Rules) != 2 {
+		t.Fatalf("Expected two lifecycle rules, got %#v", put)
+	}
+	if put.Rules[0].Raw != other {
+		t.Errorf("Expected the other rule to be kept as it was, got %q", put.Rules[0].Raw)
+	}
+	rule := put.Rules[1]
+	if rule.ID != expirationRuleID || rule.Filter == nil || rule.Filter.Prefix != "tmp/" || rule.Expiration == nil || rule.Expiration.Days != 7 {
+		t.Errorf("Expected the expiration rule for tmp/ after 7 days, got %#v", rule)
+	}
+}
+
+func TestRemoveBucketExpiration(t *testing.T) {
+	removed := false
+	c, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			w.Write([]byte(`<LifecycleConfiguration><Rule><ID>miniovol-expiration</ID><Status>Enabled</Status>` +
+				`<Filter><Prefix></Prefix></Filter><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`))
+		case "DELETE":
+			removed = true
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer ts.Close()
+
+	if err := c.RemoveBucketExpiration("testbucket"); err != nil {
+		t.Fatalf("An error occured while removing the expiration: %s", err)
+	}
+	if !removed {
+		t.Errorf("Expected the lifecycle configuration left without rules to be removed")
+	}
+}
+
+func TestBucketRetention(t *testing.T) {
+	c, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PUT":
+			cfg := objectLockConfiguration{}
+			if err := xml.NewDecoder(r.Body).Decode(&cfg); err != nil {
+				t.Fatalf("An error occured while decoding the object lock: %s", err)
+			}
+			if cfg.Rule == nil || cfg.Rule.DefaultRetention.Mode != "GOVERNANCE" || cfg.Rule.DefaultRetention.Days != 30 {
+				t.Errorf("Unexpected object lock configuration %#v", cfg)
+			}
+		case "GET":
+			w.Write([]byte(`<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled>` +
+				`<Rule><DefaultRetention><Mode>GOVERNANCE</Mode><Days>30</Days></DefaultRetention></Rule></ObjectLockConfiguration>`))
+		}
+	})
+	defer ts.Close()
+
+	if err := c.SetBucketRetention("testbucket", "governance", 30); err != nil {
+		t.Fatalf("An error occured while setting the retention: %s", err)
+	}
+	mode, days, err := c.BucketRetention("testbucket")
+	if err != nil {
+		t.Fatalf("An error occured while retrieving the retention: %s", err)
+	}
+	if mode != "GOVERNANCE" || days != 30 {
+		t.Errorf("Expected GOVERNANCE for 30 days, got %s for %d days", mode, days)
+	}
+}
+
+func TestBucketRetentionNotConfigured(t *testing.T) {
+	c, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<Error><Code>ObjectLockConfigurationNotFoundError</Code></Error>`))
+	})
+	defer ts.Close()
+
+	mode, _, err := c.BucketRetention("testbucket")
+	if err != nil || mode != "" {
+		t.Errorf("Expected no retention, got %s and %v", mode, err)
+	}
+}
+
+func TestBucketTags(t *testing.T) {
+	c, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["tagging"]; r.Method != "GET" || !ok {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+		if r.URL.Path == "/untagged" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchTagSet</Code><Message>The TagSet does not exist</Message></Error>`))
+			return
+		}
+		w.Write([]byte(`<Tagging><TagSet><Tag><Key>miniovol</Key><Value>adopt</Value></Tag></TagSet></Tagging>`))
+	})
+	defer ts.Close()
+
+	tags, err := c.BucketTags("testbucket")
+	if err != nil || !reflect.DeepEqual(tags, map[string]string{"miniovol": "adopt"}) {
+		t.Errorf("Expected the miniovol tag, got %v and %v", tags, err)
+	}
+	tags, err = c.BucketTags("untagged")
+	if err != nil || len(tags) != 0 {
+		t.Errorf("Expected no tags, got %v and %v", tags, err)
+	}
+}
+
+func TestPurgeBucket(t *testing.T) {
+	var removed []string
+	c, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			w.Write([]byte(`<ListBucketResult><Name>testbucket</Name><IsTruncated>false</IsTruncated>` +
+				`<Contents><Key>a</Key><Size>1</Size></Contents><Contents><Key>b/c</Key><Size>2</Size></Contents></ListBucketResult>`))
+		case "DELETE":
+			removed = append(removed, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer ts.Close()
+
+	if err := c.PurgeBucket("testbucket"); err != nil {
+		t.Fatalf("An error occured while purging the bucket: %s", err)
+	}
+	expected := []string{"/testbucket/a", "/testbucket/b/c", "/testbucket/"}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Errorf("Expected removals %v, got %v", expected, removed)
+	}
+}
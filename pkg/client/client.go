@@ -12,22 +12,38 @@ type MinioClient struct {
 	ServerURI       string
 	AccesKeyID      string
 	SecretAccessKey string
+	Secure          bool
+
+	// Encryption is applied to every object written by the client, nil
+	// means that objects are written unencrypted.
+	Encryption *Encryption
+
+	// Options are the provider settings of the client.
+	Options Options
+
+	// pool tracks the health of the endpoints of a client with several
+	// endpoints, it's nil otherwise.
+	pool *endpointPool
 }
 
 // NewMinioClient returns a new minio client based on passed access specs and
 // creates a new bucket if it doesn't exist.
 func NewMinioClient(serverURI, accessKeyID, secretAccessKey, bucket string, secure bool) (*MinioClient, error) {
-	c, err := minio.New(serverURI, accessKeyID, secretAccessKey, secure)
+	return NewMinioClientWithOptions(serverURI, accessKeyID, secretAccessKey, bucket, secure, Options{})
+}
 
-	if err != nil {
-		return nil, err
+// Matches returns true if the client talks to serverURI with the given
+// credentials and options. A nil client matches nothing.
+func (c *MinioClient) Matches(serverURI, accessKeyID, secretAccessKey string, secure bool, opts Options) bool {
+	if c == nil {
+		return false
 	}
-
-	return &MinioClient{
-		Client:          c,
-		ServerURI:       serverURI,
-		AccesKeyID:      accessKeyID,
-		SecretAccessKey: secretAccessKey,
-		BucketName:      bucket,
-	}, nil
+	if opts.Region == "" {
+		opts.Region = RegionFromEndpoint(serverURI)
+	}
+	return c.ServerURI == serverURI &&
+		c.AccesKeyID == accessKeyID &&
+		c.SecretAccessKey == secretAccessKey &&
+		c.Secure == secure &&
+		c.Options == opts
 }
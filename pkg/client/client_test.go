@@ -1,6 +1,10 @@
 package client
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -28,3 +32,39 @@ func TestNewMinioClient(t *testing.T) {
 		t.Errorf("Expected %s got %s", bucket, c.BucketName)
 	}
 }
+
+func TestMatches(t *testing.T) {
+	c, err := NewMinioClient("testlocal:9000", "abc123", "secretKey", "", false)
+	if err != nil {
+		t.Fatalf("An error occured while creating a new client: %#v", err)
+	}
+	if !c.Matches("testlocal:9000", "abc123", "secretKey", false, Options{}) {
+		t.Error("Expected client to match its own settings")
+	}
+	if c.Matches("testlocal:9000", "abc123", "rotated", false, Options{}) {
+		t.Error("Expected client not to match other credentials")
+	}
+	var nilClient *MinioClient
+	if nilClient.Matches("testlocal:9000", "abc123", "secretKey", false, Options{}) {
+		t.Error("Expected nil client not to match")
+	}
+}
+
+// newTestClient returns a client of the bucket testbucket on a server that
+// sends the requests to handler. The bucket locations looked up before
+// signing are answered by the server itself.
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*MinioClient, *httptest.Server) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["location"]; ok && r.Method == "GET" {
+			fmt.Fprint(w, `<LocationConstraint>us-east-1</LocationConstraint>`)
+			return
+		}
+		handler(w, r)
+	}))
+	c, err := NewMinioClient(strings.TrimPrefix(ts.URL, "http://"), "abc123", "secretKey", "testbucket", false)
+	if err != nil {
+		ts.Close()
+		t.Fatalf("An error occured while creating a new client: %#v", err)
+	}
+	return c, ts
+}
@@ -0,0 +1,175 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	minio "github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/s3utils"
+)
+
+// DefaultCopyWorkers is the number of concurrent server side copies used when
+// the caller doesn't specify one.
+const DefaultCopyWorkers = 4
+
+// CopyProgress holds the state of a running bucket copy. It is handed to the
+// progress callback after every object that was processed.
+type CopyProgress struct {
+	Total   int64
+	Copied  int64
+	Skipped int64
+	Bytes   int64
+}
+
+// copyJob is a single object that has to be copied into the destination bucket.
+type copyJob struct {
+	source string
+	target string
+	size   int64
+}
+
+// CopyPrefix copies all the objects found in srcBucket under srcPrefix into
+// the bucket of the client using server side copies. The source prefix is
+// stripped from the destination object names. Objects that already exist in
+// the destination with the same size are skipped, which allows an interrupted
+// copy to be resumed by calling CopyPrefix again with the same arguments.
+func (c *MinioClient) CopyPrefix(srcBucket, srcPrefix string, workers int, progress func(CopyProgress)) error {
+	if c.BucketName == "" {
+		return fmt.Errorf("no destination bucket set for copy")
+	}
+	if workers <= 0 {
+		workers = DefaultCopyWorkers
+	}
+	srcPrefix = normalizePrefix(srcPrefix)
+
+	existing, err := c.listSizes(c.BucketName, "")
+	if err != nil {
+		return err
+	}
+
+	var (
+		state CopyProgress
+		wg    sync.WaitGroup
+		once  sync.Once
+		// copyErr holds the first error encountered by one of the workers.
+		copyErr error
+	)
+	jobs := make(chan copyJob)
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	report := func() {
+		if progress != nil {
+			progress(CopyProgress{
+				Total:   atomic.LoadInt64(&state.Total),
+				Copied:  atomic.LoadInt64(&state.Copied),
+				Skipped: atomic.LoadInt64(&state.Skipped),
+				Bytes:   atomic.LoadInt64(&state.Bytes),
+			})
+		}
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if err := c.copyObject(job.target, job.source); err != nil {
+					once.Do(func() {
+						copyErr = fmt.Errorf("failed to copy %s: %s", job.source, err)
+					})
+					continue
+				}
+				atomic.AddInt64(&state.Copied, 1)
+				atomic.AddInt64(&state.Bytes, job.size)
+				report()
+			}
+		}()
+	}
+
+	var listErr error
+	for obj := range c.Client.ListObjectsV2(srcBucket, srcPrefix, true, doneCh) {
+		if obj.Err != nil {
+			listErr = obj.Err
+			break
+		}
+		target := copyTarget(srcPrefix, obj.Key)
+		if target == "" {
+			continue
+		}
+		atomic.AddInt64(&state.Total, 1)
+		if size, ok := existing[target]; ok && size == obj.Size {
+			atomic.AddInt64(&state.Skipped, 1)
+			report()
+			continue
+		}
+		jobs <- copyJob{
+			source: srcBucket + "/" + obj.Key,
+			target: target,
+			size:   obj.Size,
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if listErr != nil {
+		return listErr
+	}
+	return copyErr
+}
+
+// copyObject copies source, in the form bucket/object, to target in the
+// bucket of the client. minio-go doesn't allow passing headers to CopyObject,
+// so copies that have to be encrypted are sent as raw requests.
+func (c *MinioClient) copyObject(target, source string) error {
+	if c.Encryption == nil {
+		return c.Client.CopyObject(c.BucketName, target, source, minio.NewCopyConditions())
+	}
+	header := c.Encryption.Header()
+	header.Set("X-Amz-Copy-Source", s3utils.EncodePath(source))
+	_, err := c.bucketRequest("PUT", c.BucketName, target, nil, header, nil)
+	return err
+}
+
+// listSizes returns a map of object names to object sizes for all the objects
+// in bucket under prefix.
+func (c *MinioClient) listSizes(bucket, prefix string) (map[string]int64, error) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	sizes := make(map[string]int64)
+	for obj := range c.Client.ListObjectsV2(bucket, prefix, true, doneCh) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		sizes[obj.Key] = obj.Size
+	}
+	return sizes, nil
+}
+
+// SplitBucketPrefix splits a "bucket/prefix" path into its bucket and prefix.
+func SplitBucketPrefix(path string) (string, string) {
+	path = strings.TrimPrefix(path, "/")
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// normalizePrefix makes sure that a non empty prefix is treated as a
+// directory, so that "data" doesn't also match "database/".
+func normalizePrefix(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix
+	}
+	return prefix + "/"
+}
+
+// copyTarget returns the name of the destination object for key, which is key
+// without the source prefix.
+func copyTarget(prefix, key string) string {
+	return strings.TrimPrefix(key, prefix)
+}
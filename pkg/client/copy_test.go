@@ -0,0 +1,37 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestSplitBucketPrefix(t *testing.T) {
+	tests := []struct {
+		path   string
+		bucket string
+		prefix string
+	}{
+		{"seed", "seed", ""},
+		{"seed/data/set1", "seed", "data/set1"},
+		{"/seed/data/", "seed", "data/"},
+	}
+
+	for _, tt := range tests {
+		bucket, prefix := SplitBucketPrefix(tt.path)
+		if bucket != tt.bucket || prefix != tt.prefix {
+			t.Errorf("Expected %s to split into %s and %s, got %s and %s", tt.path, tt.bucket, tt.prefix, bucket, prefix)
+		}
+	}
+}
+
+func TestCopyTarget(t *testing.T) {
+	prefix := normalizePrefix("data")
+	if prefix != "data/" {
+		t.Fatalf("Expected prefix to be data/, got %s", prefix)
+	}
+	if target := copyTarget(prefix, "data/dir/file"); target != "dir/file" {
+		t.Errorf("Expected target to be dir/file, got %s", target)
+	}
+	if target := copyTarget("", "dir/file"); target != "dir/file" {
+		t.Errorf("Expected target to be dir/file, got %s", target)
+	}
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+)
+
+// Server side encryption modes supported by the plugin.
+const (
+	SSES3  = "s3"
+	SSEKMS = "kms"
+	SSEC   = "c"
+)
+
+// Encryption describes the server side encryption applied to the objects
+// written by the plugin.
+type Encryption struct {
+	Mode        string
+	KMSKeyID    string
+	CustomerKey []byte
+}
+
+// NewEncryption validates and returns a server side encryption setting.
+// KMSKeyID is only used by SSE-KMS and customerKey only by SSE-C, which
+// requires a 256 bit key.
+func NewEncryption(mode, kmsKeyID string, customerKey []byte) (*Encryption, error) {
+	switch mode {
+	case SSES3:
+	case SSEKMS:
+		if kmsKeyID == "" {
+			return nil, fmt.Errorf("a KMS key ID is required for SSE-KMS")
+		}
+	case SSEC:
+		if len(customerKey) != 32 {
+			return nil, fmt.Errorf("SSE-C requires a 32 byte key, got %d bytes", len(customerKey))
+		}
+	default:
+		return nil, fmt.Errorf("unknown server side encryption mode %s", mode)
+	}
+	return &Encryption{
+		Mode:        mode,
+		KMSKeyID:    kmsKeyID,
+		CustomerKey: customerKey,
+	}, nil
+}
+
+// Header returns the headers that have to be sent along with every object
+// write.
+func (e *Encryption) Header() http.Header {
+	header := http.Header{}
+	if e == nil {
+		return header
+	}
+	switch e.Mode {
+	case SSES3:
+		header.Set("X-Amz-Server-Side-Encryption", "AES256")
+	case SSEKMS:
+		header.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+		header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", e.KMSKeyID)
+	case SSEC:
+		sum := md5.Sum(e.CustomerKey)
+		header.Set("X-Amz-Server-Side-Encryption-Customer-Algorithm", "AES256")
+		header.Set("X-Amz-Server-Side-Encryption-Customer-Key", base64.StdEncoding.EncodeToString(e.CustomerKey))
+		header.Set("X-Amz-Server-Side-Encryption-Customer-Key-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	return header
+}
+
+type encryptionConfiguration struct {
+	XMLName xml.Name `xml:"ServerSideEncryptionConfiguration"`
+	Rule    struct {
+		Default struct {
+			SSEAlgorithm   string `xml:"SSEAlgorithm"`
+			KMSMasterKeyID string `xml:"KMSMasterKeyID,omitempty"`
+		} `xml:"ApplyServerSideEncryptionByDefault"`
+	} `xml:"Rule"`
+}
+
+// SetBucketEncryption sets the default encryption of bucket. SSE-C can't be
+// used as a bucket default, so it is refused.
+func (c *MinioClient) SetBucketEncryption(bucket string, e *Encryption) error {
+	cfg := encryptionConfiguration{}
+	switch e.Mode {
+	case SSES3:
+		cfg.Rule.Default.SSEAlgorithm = "AES256"
+	case SSEKMS:
+		cfg.Rule.Default.SSEAlgorithm = "aws:kms"
+		cfg.Rule.Default.KMSMasterKeyID = e.KMSKeyID
+	default:
+		return fmt.Errorf("server side encryption mode %s can't be set as bucket default", e.Mode)
+	}
+	body, err := xml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	_, err = c.bucketRequest("PUT", bucket, "", subResource("encryption"), nil, body)
+	return err
+}
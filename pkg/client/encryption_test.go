@@ -0,0 +1,62 @@
+package client
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/http"
+	"testing"
+)
+
+func TestNewEncryption(t *testing.T) {
+	if _, err := NewEncryption(SSEKMS, "", nil); err == nil {
+		t.Errorf("Expected SSE-KMS without a key ID to fail")
+	}
+	if _, err := NewEncryption(SSEC, "", []byte("short")); err == nil {
+		t.Errorf("Expected SSE-C with a short key to fail")
+	}
+	if _, err := NewEncryption("aes", "", nil); err == nil {
+		t.Errorf("Expected an unknown mode to fail")
+	}
+}
+
+func TestEncryptionHeader(t *testing.T) {
+	e, err := NewEncryption(SSEC, "", bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatalf("An error occured while creating the encryption: %s", err)
+	}
+	header := e.Header()
+	if header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "AES256" {
+		t.Errorf("Expected SSE-C algorithm header, got %#v", header)
+	}
+	if header.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5") == "" {
+		t.Errorf("Expected SSE-C key MD5 header, got %#v", header)
+	}
+
+	var none *Encryption
+	if len(none.Header()) != 0 {
+		t.Errorf("Expected no headers without encryption, got %#v", none.Header())
+	}
+}
+
+func TestSetBucketEncryption(t *testing.T) {
+	c, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		cfg := encryptionConfiguration{}
+		if err := xml.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			t.Fatalf("An error occured while decoding the encryption: %s", err)
+		}
+		if cfg.Rule.Default.SSEAlgorithm != "aws:kms" || cfg.Rule.Default.KMSMasterKeyID != "my-key" {
+			t.Errorf("Unexpected encryption configuration %#v", cfg)
+		}
+	})
+	defer ts.Close()
+
+	e, _ := NewEncryption(SSEKMS, "my-key", nil)
+	if err := c.SetBucketEncryption("testbucket", e); err != nil {
+		t.Errorf("An error occured while setting the encryption: %s", err)
+	}
+
+	e, _ = NewEncryption(SSEC, "", bytes.Repeat([]byte("k"), 32))
+	if err := c.SetBucketEncryption("testbucket", e); err == nil {
+		t.Errorf("Expected SSE-C bucket encryption to fail")
+	}
+}
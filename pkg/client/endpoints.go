@@ -0,0 +1,150 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Failover policies of the clients with several endpoints.
+const (
+	// FailoverOrdered sends the requests to the first healthy endpoint, in
+	// the order they were given.
+	FailoverOrdered = "ordered"
+	// FailoverRoundRobin spreads the requests across the healthy endpoints.
+	FailoverRoundRobin = "roundRobin"
+)
+
+// FailureBackoff is how long an endpoint that failed is only tried after the
+// healthy ones.
+const FailureBackoff = 30 * time.Second
+
+// ParseEndpoints splits a comma separated list of endpoints.
+func ParseEndpoints(serverURI string) []string {
+	var endpoints []string
+	for _, endpoint := range strings.Split(serverURI, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// endpointPool tracks the health of the endpoints of a client. Endpoints are
+// marked as failed when a request to them fails, and healthy again when one
+// succeeds or once FailureBackoff has passed.
+type endpointPool struct {
+	m         sync.Mutex
+	endpoints []string
+	policy    string
+	failed    map[string]time.Time
+	next      int
+	now       func() time.Time
+}
+
+func newEndpointPool(endpoints []string, policy string) (*endpointPool, error) {
+	switch policy {
+	case "", FailoverOrdered, FailoverRoundRobin:
+	default:
+		return nil, fmt.Errorf("unknown failover policy %s", policy)
+	}
+	return &endpointPool{
+		endpoints: endpoints,
+		policy:    policy,
+		failed:    make(map[string]time.Time),
+		now:       time.Now,
+	}, nil
+}
+
+// healthy reports whether endpoint can be used. The caller must hold the
+// pool lock.
+func (p *endpointPool) healthy(endpoint string) bool {
+	failed, ok := p.failed[endpoint]
+	return !ok || p.now().Sub(failed) >= FailureBackoff
+}
+
+// candidates returns the endpoints in the order a request should try them:
+// the healthy ones first, according to the policy, and then the failed ones.
+func (p *endpointPool) candidates() []string {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	start := 0
+	if p.policy == FailoverRoundRobin {
+		start = p.next
+		p.next = (p.next + 1) % len(p.endpoints)
+	}
+	var healthy, failed []string
+	for i := range p.endpoints {
+		endpoint := p.endpoints[(start+i)%len(p.endpoints)]
+		if p.healthy(endpoint) {
+			healthy = append(healthy, endpoint)
+		} else {
+			failed = append(failed, endpoint)
+		}
+	}
+	return append(healthy, failed...)
+}
+
+// current returns the first healthy endpoint in the order they were given,
+// or the first endpoint when none is healthy.
+func (p *endpointPool) current() string {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for _, endpoint := range p.endpoints {
+		if p.healthy(endpoint) {
+			return endpoint
+		}
+	}
+	return p.endpoints[0]
+}
+
+// mark records the outcome of a request to endpoint.
+func (p *endpointPool) mark(endpoint string, healthy bool) {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	if healthy {
+		delete(p.failed, endpoint)
+		return
+	}
+	p.failed[endpoint] = p.now()
+}
+
+// Endpoints returns the endpoints of the client.
+func (c *MinioClient) Endpoints() []string {
+	if c.pool == nil {
+		return []string{c.ServerURI}
+	}
+	return c.pool.endpoints
+}
+
+// Endpoint returns the endpoint mounts of the client should use, which is its
+// first healthy endpoint. Requests made by the client follow its failover
+// policy instead.
+func (c *MinioClient) Endpoint() string {
+	if c.pool == nil {
+		return c.ServerURI
+	}
+	return c.pool.current()
+}
+
+// EndpointHealthy reports whether endpoint hasn't failed recently.
+func (c *MinioClient) EndpointHealthy(endpoint string) bool {
+	if c.pool == nil {
+		return true
+	}
+	c.pool.m.Lock()
+	defer c.pool.m.Unlock()
+	return c.pool.healthy(endpoint)
+}
+
+// SetEndpointHealth records the health of one of the endpoints of the client,
+// as found by a health check.
+func (c *MinioClient) SetEndpointHealth(endpoint string, healthy bool) {
+	if c.pool != nil {
+		c.pool.mark(endpoint, healthy)
+	}
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEndpoints(t *testing.T) {
+	tests := map[string][]string{
+		"a:9000":               {"a:9000"},
+		"a:9000, b:9000,":      {"a:9000", "b:9000"},
+		" , ":                  nil,
+		"a:9000,b:9000,c:9000": {"a:9000", "b:9000", "c:9000"},
+	}
+	for server, expected := range tests {
+		if endpoints := ParseEndpoints(server); !reflect.DeepEqual(endpoints, expected) {
+			t.Errorf("Expected %v for %q, got %v", expected, server, endpoints)
+		}
+	}
+}
+
+func TestEndpointPool(t *testing.T) {
+	now := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	p, err := newEndpointPool([]string{"a", "b", "c"}, FailoverOrdered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.now = func() time.Time { return now }
+
+	p.mark("a", false)
+	if candidates := p.candidates(); !reflect.DeepEqual(candidates, []string{"b", "c", "a"}) {
+		t.Errorf("Expected the failed endpoint to be tried last, got %v", candidates)
+	}
+	if current := p.current(); current != "b" {
+		t.Errorf("Expected b to be the current endpoint, got %s", current)
+	}
+	now = now.Add(FailureBackoff)
+	if current := p.current(); current != "a" {
+		t.Errorf("Expected a to be tried again after the backoff, got %s", current)
+	}
+
+	p, _ = newEndpointPool([]string{"a", "b", "c"}, FailoverRoundRobin)
+	p.now = func() time.Time { return now }
+	p.mark("b", false)
+	var firsts []string
+	for i := 0; i < 3; i++ {
+		firsts = append(firsts, p.candidates()[0])
+	}
+	if !reflect.DeepEqual(firsts, []string{"a", "c", "c"}) {
+		t.Errorf("Expected requests to rotate across the healthy endpoints, got %v", firsts)
+	}
+
+	if _, err := newEndpointPool([]string{"a"}, "random"); err == nil {
+		t.Errorf("Expected an unknown failover policy to fail")
+	}
+}
+
+func TestFailover(t *testing.T) {
+	var hits []string
+	handler := func(name string, status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			hits = append(hits, name)
+			if !strings.Contains(r.Header.Get("Authorization"), "/us-east-1/s3/aws4_request") {
+				t.Errorf("Expected request to %s to be signed, got %s", name, r.Header.Get("Authorization"))
+			}
+			w.WriteHeader(status)
+			if status == http.StatusOK {
+				fmt.Fprint(w, `<ListAllMyBucketsResult><Buckets></Buckets></ListAllMyBucketsResult>`)
+			}
+		}
+	}
+	down := httptest.NewServer(handler("down", http.StatusServiceUnavailable))
+	defer down.Close()
+	up := httptest.NewServer(handler("up", http.StatusOK))
+	defer up.Close()
+	closed := httptest.NewServer(handler("closed", http.StatusOK))
+	closed.Close()
+
+	server := strings.Join([]string{
+		strings.TrimPrefix(closed.URL, "http://"),
+		strings.TrimPrefix(down.URL, "http://"),
+		strings.TrimPrefix(up.URL, "http://"),
+	}, ",")
+	c, err := NewMinioClientWithOptions(server, "access", "secret", "", false, Options{SignatureVersion: SignatureV4})
+	if err != nil {
+		t.Fatalf("An error occured while creating the client: %s", err)
+	}
+	if _, err := c.Client.ListBuckets(); err != nil {
+		t.Fatalf("Expected the request to fail over, got %s", err)
+	}
+	if !reflect.DeepEqual(hits, []string{"down", "up"}) {
+		t.Errorf("Expected the request to reach down and then up, got %v", hits)
+	}
+	if endpoint := c.Endpoint(); endpoint != strings.TrimPrefix(up.URL, "http://") {
+		t.Errorf("Expected the current endpoint to be up, got %s", endpoint)
+	}
+	if url := c.BucketURL("test"); url != up.URL+"/test" {
+		t.Errorf("Expected mounts to use up, got %s", url)
+	}
+
+	c.SetEndpointHealth(strings.TrimPrefix(closed.URL, "http://"), true)
+	if endpoint := c.Endpoint(); endpoint != strings.TrimPrefix(closed.URL, "http://") {
+		t.Errorf("Expected the first endpoint to be current again, got %s", endpoint)
+	}
+
+	if _, err := NewMinioClientWithOptions("s3.amazonaws.com,other:9000", "access", "secret", "", true, Options{}); err == nil {
+		t.Errorf("Expected AWS endpoints not to fail over")
+	}
+}
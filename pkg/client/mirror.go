@@ -0,0 +1,156 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	minio "github.com/minio/minio-go"
+)
+
+// MirrorResult counts what a mirror did to the target bucket.
+type MirrorResult struct {
+	Copied  int `json:"copied"`
+	Removed int `json:"removed"`
+	Skipped int `json:"skipped"`
+}
+
+// Change is a change of an object reported by the bucket notifications, or
+// the error that stopped them.
+type Change struct {
+	Key     string
+	Removed bool
+	Err     error
+}
+
+// Mirror makes the bucket of target a copy of the bucket of the client.
+// Objects missing from target, of a different size or modified since they
+// were copied there are copied again, objects only found in target are
+// removed.
+func (c *MinioClient) Mirror(target *MinioClient) (MirrorResult, error) {
+	result := MirrorResult{}
+	if c.BucketName == "" || target.BucketName == "" {
+		return result, fmt.Errorf("no bucket set for mirror")
+	}
+	existing, err := target.listObjects(target.BucketName)
+	if err != nil {
+		return result, err
+	}
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	for obj := range c.Client.ListObjectsV2(c.BucketName, "", true, doneCh) {
+		if obj.Err != nil {
+			return result, obj.Err
+		}
+		copied, ok := existing[obj.Key]
+		delete(existing, obj.Key)
+		if ok && copied.Size == obj.Size && !copied.LastModified.Before(obj.LastModified) {
+			result.Skipped++
+			continue
+		}
+		if err := c.mirrorObject(target, obj.Key); err != nil {
+			return result, fmt.Errorf("failed to mirror %s: %s", obj.Key, err)
+		}
+		result.Copied++
+	}
+	for key := range existing {
+		if err := target.Client.RemoveObject(target.BucketName, key); err != nil {
+			return result, fmt.Errorf("failed to remove %s from mirror: %s", key, err)
+		}
+		result.Removed++
+	}
+	return result, nil
+}
+
+// MirrorObject copies the object key to target, or removes it from target
+// when it's gone from the bucket of the client.
+func (c *MinioClient) MirrorObject(target *MinioClient, key string) error {
+	if _, err := c.Client.StatObject(c.BucketName, key); err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+			return err
+		}
+		err := target.Client.RemoveObject(target.BucketName, key)
+		if err != nil && minio.ToErrorResponse(err).Code != "NoSuchKey" {
+			return err
+		}
+		return nil
+	}
+	return c.mirrorObject(target, key)
+}
+
+// mirrorObject streams the object key to target, with its content type and
+// the metadata kept by exports.
+func (c *MinioClient) mirrorObject(target *MinioClient, key string) error {
+	obj, err := c.Client.GetObject(c.BucketName, key)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	info, err := obj.Stat()
+	if err != nil {
+		return err
+	}
+
+	metaData := map[string][]string(target.Encryption.Header())
+	if info.ContentType != "" {
+		metaData["Content-Type"] = []string{info.ContentType}
+	}
+	for header, values := range info.Metadata {
+		if preservedHeader(header) {
+			metaData[header] = values
+		}
+	}
+	_, err = target.Client.PutObjectWithMetadata(target.BucketName, key, sizedReader{obj, info.Size}, metaData, nil)
+	return err
+}
+
+// listObjects returns the objects of bucket by name.
+func (c *MinioClient) listObjects(bucket string) (map[string]minio.ObjectInfo, error) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	objects := make(map[string]minio.ObjectInfo)
+	for obj := range c.Client.ListObjectsV2(bucket, "", true, doneCh) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		objects[obj.Key] = obj
+	}
+	return objects, nil
+}
+
+// Changes returns the objects created or removed in the bucket of the client
+// as they are reported by the bucket notifications, until doneCh is closed.
+// Only MinIO servers support listening to notifications, the channel reports
+// an error and is closed for the other providers.
+func (c *MinioClient) Changes(doneCh <-chan struct{}) <-chan Change {
+	changes := make(chan Change)
+	events := []string{string(minio.ObjectCreatedAll), minio.ObjectRemovedAll}
+	go func() {
+		defer close(changes)
+		for info := range c.Client.ListenBucketNotification(c.BucketName, "", "", events, doneCh) {
+			if info.Err != nil {
+				select {
+				case changes <- Change{Err: info.Err}:
+				case <-doneCh:
+				}
+				return
+			}
+			for _, record := range info.Records {
+				// Keys are URL encoded in the events, like S3 does.
+				key, err := url.QueryUnescape(record.S3.Object.Key)
+				if err != nil {
+					key = record.S3.Object.Key
+				}
+				change := Change{Key: key, Removed: strings.HasPrefix(record.EventName, "s3:ObjectRemoved:")}
+				select {
+				case changes <- change:
+				case <-doneCh:
+					return
+				}
+			}
+		}
+	}()
+	return changes
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMirror(t *testing.T) {
+	src, srcS3, closeSrc := newFakeS3Client(t)
+	defer closeSrc()
+	dst, dstS3, closeDst := newFakeS3Client(t)
+	defer closeDst()
+
+	srcS3.objects["a.txt"] = fakeObject{data: []byte("a"), header: http.Header{"Content-Type": {"text/plain"}}}
+	srcS3.objects["b.txt"] = fakeObject{data: []byte("bbb")}
+	dstS3.objects["b.txt"] = fakeObject{data: []byte("b")}
+	dstS3.objects["c.txt"] = fakeObject{data: []byte("c")}
+
+	result, err := src.Mirror(dst)
+	if err != nil {
+		t.Fatalf("An error occured while mirroring: %s", err)
+	}
+	if result != (MirrorResult{Copied: 2, Removed: 1}) {
+		t.Errorf("Expected a.txt and b.txt to be copied and c.txt removed, got %#v", result)
+	}
+	if string(dstS3.objects["b.txt"].data) != "bbb" || dstS3.objects["a.txt"].header.Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected the mirror to match the source, got %#v", dstS3.objects)
+	}
+	if result, err := src.Mirror(dst); err != nil || result != (MirrorResult{Skipped: 2}) {
+		t.Errorf("Expected a mirror of an unchanged bucket to skip everything, got %#v and %v", result, err)
+	}
+
+	delete(srcS3.objects, "a.txt")
+	if err := src.MirrorObject(dst, "a.txt"); err != nil {
+		t.Fatalf("An error occured while mirroring a removed object: %s", err)
+	}
+	if _, ok := dstS3.objects["a.txt"]; ok {
+		t.Errorf("Expected a.txt to be removed from the mirror")
+	}
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	minio "github.com/minio/minio-go"
+)
+
+// Signature versions.
+const (
+	SignatureV2 = "v2"
+	SignatureV4 = "v4"
+)
+
+// Bucket lookup styles.
+const (
+	// LookupAuto lets minio-go decide, it uses virtual host style for AWS
+	// and Google only.
+	LookupAuto = ""
+	// LookupPath addresses buckets as http://endpoint/bucket.
+	LookupPath = "path"
+	// LookupVirtualHost addresses buckets as http://bucket.endpoint.
+	LookupVirtualHost = "virtual"
+)
+
+// awsEndpoint is the global endpoint of AWS S3.
+const awsEndpoint = "s3.amazonaws.com"
+
+// Options are the settings a client needs to talk to S3 providers other than
+// MinIO.
+type Options struct {
+	// Region is the region buckets are created in and requests are signed
+	// for. When empty, it's derived from the endpoint of the providers that
+	// have regional endpoints.
+	Region string
+	// Lookup is the bucket lookup style. AWS S3 is always addressed in
+	// virtual host style by minio-go.
+	Lookup string
+	// SignatureVersion forces a signature version, minio-go picks one when
+	// empty.
+	SignatureVersion string
+	// Failover is the failover policy of the clients with several
+	// endpoints, FailoverOrdered when empty.
+	Failover string
+}
+
+// regEndpointRegion matches the regional endpoints of AWS S3, Wasabi and
+// Backblaze B2, like s3.eu-west-1.amazonaws.com or
+// s3.us-west-002.backblazeb2.com.
+var regEndpointRegion = regexp.MustCompile(`^s3[.-]([a-z0-9-]+)\.(amazonaws\.com|wasabisys\.com|backblazeb2\.com)(:[0-9]+)?$`)
+
+// RegionFromEndpoint returns the region of a regional endpoint of a known
+// provider, or an empty string.
+func RegionFromEndpoint(endpoint string) string {
+	match := regEndpointRegion.FindStringSubmatch(endpoint)
+	if match == nil || match[1] == "external-1" {
+		return ""
+	}
+	return match[1]
+}
+
+// NewMinioClientWithOptions returns a new minio client for an S3 provider
+// that needs options. serverURI may hold several endpoints separated by
+// commas, which are replicas of each other the client fails over between.
+func NewMinioClientWithOptions(serverURI, accessKeyID, secretAccessKey, bucket string, secure bool, opts Options) (*MinioClient, error) {
+	endpoints := ParseEndpoints(serverURI)
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoint given")
+	}
+	endpoint := endpoints[0]
+	if opts.Region == "" {
+		opts.Region = RegionFromEndpoint(endpoint)
+	}
+	pool, err := newEndpointPool(endpoints, opts.Failover)
+	if err != nil {
+		return nil, err
+	}
+
+	// minio-go only accepts the global AWS endpoint, from which it reaches
+	// the regional endpoint of each bucket by itself.
+	if strings.HasSuffix(strings.Split(endpoint, ":")[0], ".amazonaws.com") {
+		if len(endpoints) > 1 {
+			return nil, fmt.Errorf("AWS S3 endpoints can't fail over")
+		}
+		endpoint = awsEndpoint
+	}
+
+	var c *minio.Client
+	switch opts.SignatureVersion {
+	case "":
+		c, err = minio.New(endpoint, accessKeyID, secretAccessKey, secure)
+	case SignatureV4:
+		c, err = minio.NewV4(endpoint, accessKeyID, secretAccessKey, secure)
+	case SignatureV2:
+		if opts.Lookup == LookupVirtualHost {
+			return nil, fmt.Errorf("virtual host style requests need signature %s", SignatureV4)
+		}
+		c, err = minio.NewV2(endpoint, accessKeyID, secretAccessKey, secure)
+	default:
+		return nil, fmt.Errorf("unknown signature version %s", opts.SignatureVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch opts.Lookup {
+	case LookupAuto, LookupPath, LookupVirtualHost:
+	default:
+		return nil, fmt.Errorf("unknown bucket lookup style %s", opts.Lookup)
+	}
+
+	mc := &MinioClient{
+		Client:          c,
+		ServerURI:       serverURI,
+		AccesKeyID:      accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		BucketName:      bucket,
+		Secure:          secure,
+		Options:         opts,
+	}
+	if len(endpoints) > 1 {
+		mc.pool = pool
+	}
+	mc.SetTransport(http.DefaultTransport)
+	return mc, nil
+}
+
+// region returns the region requests are signed for.
+func (c *MinioClient) region() string {
+	if c.Options.Region != "" {
+		return c.Options.Region
+	}
+	return defaultRegion
+}
+
+// BucketURL returns the URL of bucket on the current endpoint of the client,
+// in its lookup style.
+func (c *MinioClient) BucketURL(bucket string) string {
+	scheme := "http"
+	if c.Secure {
+		scheme = "https"
+	}
+	if c.Options.Lookup == LookupVirtualHost {
+		return fmt.Sprintf("%s://%s.%s", scheme, bucket, c.Endpoint())
+	}
+	return fmt.Sprintf("%s://%s/%s", scheme, c.Endpoint(), bucket)
+}
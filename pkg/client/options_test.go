@@ -0,0 +1,246 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegionFromEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"s3.eu-west-1.amazonaws.com":     "eu-west-1",
+		"s3-eu-west-1.amazonaws.com":     "eu-west-1",
+		"s3.amazonaws.com":               "",
+		"s3-external-1.amazonaws.com":    "",
+		"s3.eu-central-1.wasabisys.com":  "eu-central-1",
+		"s3.us-west-002.backblazeb2.com": "us-west-002",
+		"rgw.example.com:7480":           "",
+		"localhost:9000":                 "",
+	}
+	for endpoint, region := range tests {
+		if r := RegionFromEndpoint(endpoint); r != region {
+			t.Errorf("Expected region %q for %s, got %q", region, endpoint, r)
+		}
+	}
+}
+
+func TestBucketURL(t *testing.T) {
+	tests := []struct {
+		secure   bool
+		lookup   string
+		expected string
+	}{
+		{false, LookupAuto, "http://s3.example.com/testbucket"},
+		{true, LookupPath, "https://s3.example.com/testbucket"},
+		{true, LookupVirtualHost, "https://testbucket.s3.example.com"},
+	}
+	for _, test := range tests {
+		c, err := NewMinioClientWithOptions("s3.example.com", "abc123", "secretKey", "", test.secure, Options{Lookup: test.lookup})
+		if err != nil {
+			t.Fatalf("An error occured while creating the client: %s", err)
+		}
+		if u := c.BucketURL("testbucket"); u != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, u)
+		}
+	}
+}
+
+func TestNewMinioClientWithOptionsInvalid(t *testing.T) {
+	invalid := []Options{
+		{SignatureVersion: "v3"},
+		{Lookup: "dns"},
+		{SignatureVersion: SignatureV2, Lookup: LookupVirtualHost},
+	}
+	for _, opts := range invalid {
+		if _, err := NewMinioClientWithOptions("s3.example.com", "abc123", "secretKey", "", false, opts); err == nil {
+			t.Errorf("Expected options %#v to be refused", opts)
+		}
+	}
+}
+
+// fakeProvider records the requests of a client, without sending them.
+type fakeProvider struct {
+	requests []*http.Request
+	bodies   []string
+}
+
+func (f *fakeProvider) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.requests = append(f.requests, req)
+	body := ""
+	if req.Body != nil {
+		data, _ := ioutil.ReadAll(req.Body)
+		body = string(data)
+	}
+	f.bodies = append(f.bodies, body)
+
+	resp := `<ListAllMyBucketsResult><Buckets></Buckets></ListAllMyBucketsResult>`
+	if _, ok := req.URL.Query()["location"]; ok {
+		resp = `<LocationConstraint>eu-west-1</LocationConstraint>`
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(bytes.NewReader([]byte(resp))),
+		Request:    req,
+	}, nil
+}
+
+func TestProviderQuirks(t *testing.T) {
+	tests := []struct {
+		provider string
+		endpoint string
+		opts     Options
+		host     string
+		path     string
+		auth     string
+	}{
+		{
+			provider: "aws s3 regional endpoint",
+			endpoint: "s3.eu-west-1.amazonaws.com",
+			opts:     Options{},
+			host:     "s3.amazonaws.com",
+			path:     "/testbucket/",
+			auth:     "/eu-west-1/s3/aws4_request",
+		},
+		{
+			provider: "virtual host style",
+			endpoint: "s3.example.com",
+			opts:     Options{Region: "eu-west-1", Lookup: LookupVirtualHost},
+			host:     "testbucket.s3.example.com",
+			path:     "/",
+			auth:     "/eu-west-1/s3/aws4_request",
+		},
+		{
+			provider: "ceph rgw",
+			endpoint: "rgw.example.com:7480",
+			opts:     Options{Region: "default", Lookup: LookupPath},
+			host:     "rgw.example.com:7480",
+			path:     "/testbucket/",
+			auth:     "/default/s3/aws4_request",
+		},
+		{
+			provider: "wasabi",
+			endpoint: "s3.eu-central-1.wasabisys.com",
+			opts:     Options{},
+			host:     "s3.eu-central-1.wasabisys.com",
+			path:     "/testbucket/",
+			auth:     "/eu-central-1/s3/aws4_request",
+		},
+		{
+			provider: "backblaze b2",
+			endpoint: "s3.us-west-002.backblazeb2.com",
+			opts:     Options{SignatureVersion: SignatureV4},
+			host:     "s3.us-west-002.backblazeb2.com",
+			path:     "/testbucket/",
+			auth:     "/us-west-002/s3/aws4_request",
+		},
+		{
+			provider: "legacy signature v2",
+			endpoint: "storage.example.com",
+			opts:     Options{SignatureVersion: SignatureV2},
+			host:     "storage.example.com",
+			path:     "/testbucket/",
+			auth:     "AWS abc123:",
+		},
+	}
+	for _, test := range tests {
+		c, err := NewMinioClientWithOptions(test.endpoint, "abc123", "secretKey", "", false, test.opts)
+		if err != nil {
+			t.Fatalf("%s: an error occured while creating the client: %s", test.provider, err)
+		}
+		f := &fakeProvider{}
+		c.SetTransport(f)
+
+		if err := c.Client.MakeBucket("testbucket", c.Options.Region); err != nil {
+			t.Fatalf("%s: an error occured while creating the bucket: %s", test.provider, err)
+		}
+		req := f.requests[len(f.requests)-1]
+		if req.Method != "PUT" || req.URL.Host != test.host || req.URL.Path != test.path {
+			t.Errorf("%s: expected PUT %s%s, got %s %s%s", test.provider, test.host, test.path, req.Method, req.URL.Host, req.URL.Path)
+		}
+		if req.Host != test.host {
+			t.Errorf("%s: expected host header %s, got %s", test.provider, test.host, req.Host)
+		}
+		if !strings.Contains(req.Header.Get("Authorization"), test.auth) {
+			t.Errorf("%s: expected authorization with %q, got %q", test.provider, test.auth, req.Header.Get("Authorization"))
+		}
+		body := f.bodies[len(f.bodies)-1]
+		if region := c.Options.Region; region != "" && !strings.Contains(body, "<LocationConstraint>"+region+"</LocationConstraint>") {
+			t.Errorf("%s: expected location constraint %s, got %q", test.provider, region, body)
+		}
+	}
+}
+
+func TestMakeLockedBucketLocation(t *testing.T) {
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		if !strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/s3/aws4_request") {
+			t.Errorf("Expected request to be signed for eu-west-1, got %s", r.Header.Get("Authorization"))
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewMinioClientWithOptions(strings.TrimPrefix(ts.URL, "http://"), "abc123", "secretKey", "", false, Options{Region: "eu-west-1"})
+	if err != nil {
+		t.Fatalf("An error occured while creating the client: %s", err)
+	}
+	if err := c.MakeLockedBucket("testbucket", "eu-west-1"); err != nil {
+		t.Fatalf("An error occured while creating the bucket: %s", err)
+	}
+	if !strings.Contains(body, "<LocationConstraint>eu-west-1</LocationConstraint>") {
+		t.Errorf("Expected a location constraint, got %q", body)
+	}
+}
+
+func TestBucketPath(t *testing.T) {
+	tests := []struct {
+		lookup string
+		object string
+		host   string
+		path   string
+	}{
+		{LookupAuto, "", "s3.example.com", "/testbucket"},
+		{LookupPath, "data/a.txt", "s3.example.com", "/testbucket/data/a.txt"},
+		{LookupVirtualHost, "", "testbucket.s3.example.com", "/"},
+		{LookupVirtualHost, "data/a.txt", "testbucket.s3.example.com", "/data/a.txt"},
+	}
+	for _, test := range tests {
+		c, err := NewMinioClientWithOptions("s3.example.com", "abc123", "secretKey", "", false, Options{Lookup: test.lookup})
+		if err != nil {
+			t.Fatalf("An error occured while creating the client: %s", err)
+		}
+		if host, path := c.bucketPath("testbucket", test.object); host != test.host || path != test.path {
+			t.Errorf("Expected %s%s, got %s%s", test.host, test.path, host, path)
+		}
+	}
+}
+
+func TestBucketRequestRegion(t *testing.T) {
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["location"]; ok {
+			fmt.Fprint(w, `<LocationConstraint>eu-central-1</LocationConstraint>`)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		fmt.Fprint(w, `<VersioningConfiguration><Status>Enabled</Status></VersioningConfiguration>`)
+	}))
+	defer ts.Close()
+
+	c, err := NewMinioClient(strings.TrimPrefix(ts.URL, "http://"), "abc123", "secretKey", "testbucket", false)
+	if err != nil {
+		t.Fatalf("An error occured while creating the client: %s", err)
+	}
+	if _, err := c.BucketVersioning("testbucket"); err != nil {
+		t.Fatalf("An error occured while reading the versioning: %s", err)
+	}
+	if !strings.Contains(auth, "/eu-central-1/s3/aws4_request") {
+		t.Errorf("Expected the request to be signed for the location of the bucket, got %s", auth)
+	}
+}
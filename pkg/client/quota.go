@@ -0,0 +1,73 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// bucketQuota is the body of the MinIO admin set-bucket-quota call.
+type bucketQuota struct {
+	Quota     int64  `json:"quota"`
+	QuotaType string `json:"quotatype"`
+}
+
+// SetBucketQuota sets a hard size quota in bytes on bucket through the MinIO
+// admin API. Servers that don't implement the admin API return a
+// RequestError for which NotImplemented is true.
+func (c *MinioClient) SetBucketQuota(bucket string, size int64) error {
+	body, err := json.Marshal(bucketQuota{
+		Quota:     size,
+		QuotaType: "hard",
+	})
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	query.Set("bucket", bucket)
+	_, err = c.executeRequest("PUT", "/minio/admin/v3/set-bucket-quota", query, nil, body)
+	return err
+}
+
+// dataUsage is the part of the answer of the MinIO admin datausageinfo call
+// that holds the usage of the buckets.
+type dataUsage struct {
+	Buckets map[string]struct {
+		Size    int64 `json:"size"`
+		Objects int64 `json:"objectsCount"`
+	} `json:"bucketsUsageInfo"`
+}
+
+// BucketUsage returns the total size in bytes and the number of objects
+// stored in bucket. The usage is taken from the MinIO admin API, which
+// reports what the server last scanned. The bucket is listed when the server
+// doesn't implement the admin API or hasn't scanned the bucket yet.
+func (c *MinioClient) BucketUsage(bucket string) (int64, int64, error) {
+	data, err := c.executeRequest("GET", "/minio/admin/v3/datausageinfo", nil, nil, nil)
+	if _, ok := err.(RequestError); err != nil && !ok {
+		return 0, 0, err
+	}
+	if err == nil {
+		usage := dataUsage{}
+		if err := json.Unmarshal(data, &usage); err != nil {
+			return 0, 0, fmt.Errorf("invalid data usage: %s", err)
+		}
+		if u, ok := usage.Buckets[bucket]; ok {
+			return u.Size, u.Objects, nil
+		}
+	}
+	return c.listUsage(bucket)
+}
+
+// listUsage returns the usage of bucket by listing its objects.
+func (c *MinioClient) listUsage(bucket string) (int64, int64, error) {
+	sizes, err := c.listSizes(bucket, "")
+	if err != nil {
+		return 0, 0, err
+	}
+	var total int64
+	for _, size := range sizes {
+		total += size
+	}
+	return total, int64(len(sizes)), nil
+}
@@ -0,0 +1,76 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSetBucketQuota(t *testing.T) {
+	c, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/minio/admin/v3/set-bucket-quota" || r.URL.Query().Get("bucket") != "testbucket" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
+			t.Errorf("Expected request to be signed, got %#v", r.Header)
+		}
+		quota := bucketQuota{}
+		if err := json.NewDecoder(r.Body).Decode(&quota); err != nil {
+			t.Errorf("An error occured while decoding the quota: %s", err)
+		}
+		if quota.Quota != 1024 || quota.QuotaType != "hard" {
+			t.Errorf("Unexpected quota %#v", quota)
+		}
+	})
+	defer ts.Close()
+
+	if err := c.SetBucketQuota("testbucket", 1024); err != nil {
+		t.Errorf("An error occured while setting the quota: %s", err)
+	}
+}
+
+func TestSetBucketQuotaNotImplemented(t *testing.T) {
+	c, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+		w.Write([]byte(`<Error><Code>NotImplemented</Code><Message>not supported</Message></Error>`))
+	})
+	defer ts.Close()
+
+	err := c.SetBucketQuota("testbucket", 1024)
+	reqErr, ok := err.(RequestError)
+	if !ok {
+		t.Fatalf("Expected a RequestError, got %#v", err)
+	}
+	if !reqErr.NotImplemented() || reqErr.Code != "NotImplemented" {
+		t.Errorf("Expected a not implemented error, got %#v", reqErr)
+	}
+}
+
+func TestBucketUsage(t *testing.T) {
+	c, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/minio/admin/v3/datausageinfo" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"bucketsUsageInfo": {"testbucket": {"size": 2048, "objectsCount": 3}}}`))
+	})
+	defer ts.Close()
+
+	size, objects, err := c.BucketUsage("testbucket")
+	if err != nil || size != 2048 || objects != 3 {
+		t.Errorf("Expected the usage reported by the admin API, got %d, %d and %v", size, objects, err)
+	}
+}
+
+func TestBucketUsageListing(t *testing.T) {
+	c, f, closeS3 := newFakeS3Client(t)
+	defer closeS3()
+	f.objects["a.txt"] = fakeObject{data: []byte("abc")}
+	f.objects["b.txt"] = fakeObject{data: []byte("de")}
+
+	// The fake server doesn't implement the admin API.
+	size, objects, err := c.BucketUsage("testbucket")
+	if err != nil || size != 5 || objects != 2 {
+		t.Errorf("Expected the usage of the listing, got %d, %d and %v", size, objects, err)
+	}
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/pkg/s3signer"
+)
+
+// defaultRegion is the region used to sign requests that minio-go doesn't
+// cover, matching the default used by minio-go itself.
+const defaultRegion = "us-east-1"
+
+// RequestTimeout is how long a raw request may take, body included.
+const RequestTimeout = 30 * time.Second
+
+// requestClient sends the raw requests.
+var requestClient = &http.Client{Timeout: RequestTimeout}
+
+// RequestError is returned when the server answers a raw request with a non
+// successful status code.
+type RequestError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e RequestError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("request failed with status %d: %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// NotImplemented reports whether the server doesn't support the request, for
+// example when the MinIO admin API is used against another S3 provider.
+func (e RequestError) NotImplemented() bool {
+	return e.StatusCode == http.StatusNotImplemented ||
+		e.StatusCode == http.StatusNotFound ||
+		e.Code == "NotImplemented"
+}
+
+// executeRequest signs and executes a request against the server of the
+// client for the MinIO admin API, which minio-go doesn't expose. It returns
+// the body of the response. Requests are always sent path style, since the
+// admin API paths aren't buckets, to the current endpoint of the client.
+func (c *MinioClient) executeRequest(method, path string, query url.Values, header http.Header, body []byte) ([]byte, error) {
+	return c.sendRequest(method, c.Endpoint(), path, c.region(), query, header, body)
+}
+
+// bucketRequest signs and executes a request on bucket, or on one of its
+// objects when object isn't empty, for the APIs that minio-go doesn't expose
+// like bucket sub-resources. The bucket is addressed in the lookup style of
+// the client and the request is signed for its region.
+func (c *MinioClient) bucketRequest(method, bucket, object string, query url.Values, header http.Header, body []byte) ([]byte, error) {
+	host, path := c.bucketPath(bucket, object)
+	return c.sendRequest(method, host, path, c.bucketRegion(bucket), query, header, body)
+}
+
+// bucketPath returns the host and path of bucket, or of object in it, on the
+// current endpoint of the client in its lookup style.
+func (c *MinioClient) bucketPath(bucket, object string) (string, string) {
+	if c.Options.Lookup == LookupVirtualHost {
+		return bucket + "." + c.Endpoint(), "/" + object
+	}
+	if object == "" {
+		return c.Endpoint(), "/" + bucket
+	}
+	return c.Endpoint(), "/" + bucket + "/" + object
+}
+
+// bucketRegion returns the region the requests on bucket are signed for, the
+// region of the client when it has one and the location of the bucket
+// otherwise. minio-go caches the locations it looked up.
+func (c *MinioClient) bucketRegion(bucket string) string {
+	if c.Options.Region != "" {
+		return c.Options.Region
+	}
+	location, err := c.Client.GetBucketLocation(bucket)
+	if err != nil || location == "" {
+		return defaultRegion
+	}
+	return location
+}
+
+// sendRequest signs a request for region and sends it to host.
+func (c *MinioClient) sendRequest(method, host, path, region string, query url.Values, header http.Header, body []byte) ([]byte, error) {
+	scheme := "http"
+	if c.Secure {
+		scheme = "https"
+	}
+	u := url.URL{
+		Scheme:   scheme,
+		Host:     host,
+		Path:     path,
+		RawPath:  escapePath(path),
+		RawQuery: query.Encode(),
+	}
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if len(body) > 0 {
+		md5Sum := md5.Sum(body)
+		req.Header.Set("Content-Md5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+	}
+	sum := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	req.ContentLength = int64(len(body))
+	req = s3signer.SignV4(*req, c.AccesKeyID, c.SecretAccessKey, region)
+
+	resp, err := requestClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newRequestError(resp.StatusCode, data)
+	}
+	return data, nil
+}
+
+// escapePath escapes each segment of path, so that the object keys holding
+// characters like ?, # or ; reach the server as they are.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func newRequestError(status int, body []byte) error {
+	reqErr := RequestError{
+		StatusCode: status,
+		Message:    string(body),
+	}
+	s3Err := struct {
+		Code    string
+		Message string
+	}{}
+	if err := xml.Unmarshal(body, &s3Err); err == nil {
+		reqErr.Code = s3Err.Code
+		reqErr.Message = s3Err.Message
+	}
+	return reqErr
+}
@@ -0,0 +1,270 @@
+package client
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SeedTimeout is how long downloading a seed archive may take, and
+// MaxSeedSize the largest seed archive that is downloaded.
+const (
+	SeedTimeout       = 30 * time.Minute
+	MaxSeedSize int64 = 16 << 30
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+
+	seedClient = &http.Client{Timeout: SeedTimeout}
+)
+
+// entryFunc is called for every regular file found while walking a seed
+// source.
+type entryFunc func(name string, r io.Reader, size int64) error
+
+// sizedReader exposes the size of the underlying reader to minio-go, which
+// then decides whether to upload the object in a single PUT or in parts.
+type sizedReader struct {
+	io.Reader
+	size int64
+}
+
+func (r sizedReader) Size() int64 {
+	return r.size
+}
+
+// Seed populates the bucket of the client with the contents of source, which
+// can be a local directory, a local tar, tar.gz or zip archive or an http(s)
+// URL pointing to such an archive. It returns the number of uploaded objects.
+// minio-go doesn't send the SSE-C headers with the parts of large files, so
+// SSE-C encrypted buckets can't be seeded.
+func (c *MinioClient) Seed(source string) (int, error) {
+	if c.BucketName == "" {
+		return 0, fmt.Errorf("no destination bucket set for seed")
+	}
+	if c.Encryption != nil && c.Encryption.Mode == SSEC {
+		return 0, fmt.Errorf("buckets encrypted with SSE-C can't be seeded")
+	}
+
+	count := 0
+	upload := func(name string, r io.Reader, size int64) error {
+		metaData := map[string][]string(c.Encryption.Header())
+		metaData["Content-Type"] = []string{contentType(name)}
+		if _, err := c.Client.PutObjectWithMetadata(c.BucketName, name, sizedReader{r, size}, metaData, nil); err != nil {
+			return fmt.Errorf("failed to upload %s: %s", name, err)
+		}
+		count++
+		return nil
+	}
+
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		err = walkURL(source, MaxSeedSize, upload)
+	} else {
+		err = walkPath(source, upload)
+	}
+	return count, err
+}
+
+// walkURL downloads the archive found at url, which must not be larger than
+// limit, and walks its contents.
+func walkURL(url string, limit int64, fn entryFunc) error {
+	resp, err := seedClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	if resp.ContentLength > limit {
+		return fmt.Errorf("%s is larger than %d bytes", url, limit)
+	}
+
+	br := bufio.NewReader(&limitedReader{r: resp.Body, limit: limit})
+	magic, _ := br.Peek(len(zipMagic))
+	if !bytes.Equal(magic, zipMagic) {
+		return walkTar(br, fn)
+	}
+
+	// zip archives need random access, so they are spooled to disk first.
+	tmp, err := ioutil.TempFile("", "miniovol-seed-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, br)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return err
+	}
+	return walkZip(zr, fn)
+}
+
+// limitedReader fails once more than limit bytes are read from r.
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		return n, fmt.Errorf("download is larger than %d bytes", l.limit)
+	}
+	return n, err
+}
+
+// walkPath walks a local directory or archive.
+func walkPath(source string, fn entryFunc) error {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return walkDir(source, fn)
+	}
+
+	fh, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	magic := make([]byte, len(zipMagic))
+	n, _ := io.ReadFull(fh, magic)
+	if _, err := fh.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if bytes.Equal(magic[:n], zipMagic) {
+		zr, err := zip.NewReader(fh, fi.Size())
+		if err != nil {
+			return err
+		}
+		return walkZip(zr, fn)
+	}
+	return walkTar(fh, fn)
+}
+
+// walkDir calls fn for every regular file found under root.
+func walkDir(root string, fn entryFunc) error {
+	return filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		fh, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer fh.Close()
+		return fn(filepath.ToSlash(rel), fh, fi.Size())
+	})
+}
+
+// walkTar calls fn for every regular file in a tar or gzipped tar stream.
+func walkTar(r io.Reader, fn entryFunc) error {
+	tr, closer, err := openTar(r)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		name := entryName(hdr.Name)
+		if name == "" {
+			continue
+		}
+		if err := fn(name, tr, hdr.Size); err != nil {
+			return err
+		}
+	}
+}
+
+// openTar returns a reader for a tar or gzipped tar stream, along with what
+// has to be closed once it's read.
+func openTar(r io.Reader) (*tar.Reader, io.Closer, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tar.NewReader(gr), gr, nil
+	}
+	return tar.NewReader(br), ioutil.NopCloser(br), nil
+}
+
+// walkZip calls fn for every regular file in a zip archive.
+func walkZip(zr *zip.Reader, fn entryFunc) error {
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		name := entryName(f.Name)
+		if name == "" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = fn(name, rc, int64(f.UncompressedSize64))
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entryName turns an archive entry name into an object name, resolving any
+// relative path elements against the root of the archive.
+func entryName(name string) string {
+	name = path.Clean("/" + strings.Replace(name, "\\", "/", -1))
+	return strings.TrimPrefix(name, "/")
+}
+
+func contentType(name string) string {
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
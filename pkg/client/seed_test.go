@@ -0,0 +1,111 @@
+package client
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var seedFiles = map[string]string{
+	"config.yml":     "key: value",
+	"certs/ca.pem":   "certificate",
+	"../escaped.txt": "escaped",
+}
+
+var seedObjects = map[string]string{
+	"config.yml":   "key: value",
+	"certs/ca.pem": "certificate",
+	"escaped.txt":  "escaped",
+}
+
+func collect(t *testing.T) (map[string]string, entryFunc) {
+	objects := make(map[string]string)
+	return objects, func(name string, r io.Reader, size int64) error {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) != size {
+			t.Errorf("Expected %s to be %d bytes, got %d", name, size, len(data))
+		}
+		objects[name] = string(data)
+		return nil
+	}
+}
+
+func TestWalkTarGzip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: "certs/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range seedFiles {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gw.Close()
+
+	objects, fn := collect(t)
+	if err := walkTar(buf, fn); err != nil {
+		t.Fatalf("An error occured while walking the archive: %s", err)
+	}
+	if !reflect.DeepEqual(objects, seedObjects) {
+		t.Errorf("Expected %#v, got %#v", seedObjects, objects)
+	}
+}
+
+func TestWalkZip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range seedFiles {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects, fn := collect(t)
+	if err := walkZip(zr, fn); err != nil {
+		t.Fatalf("An error occured while walking the archive: %s", err)
+	}
+	if !reflect.DeepEqual(objects, seedObjects) {
+		t.Errorf("Expected %#v, got %#v", seedObjects, objects)
+	}
+}
+
+func TestWalkURLLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Without a content length, the limit is only hit while the zip
+		// archive is spooled.
+		w.(http.Flusher).Flush()
+		w.Write(append(zipMagic, bytes.Repeat([]byte{0}, 2048)...))
+	}))
+	defer ts.Close()
+
+	_, fn := collect(t)
+	err := walkURL(ts.URL, 1024, fn)
+	if err == nil || !strings.Contains(err.Error(), "larger than 1024 bytes") {
+		t.Errorf("Expected the download to be refused past the limit, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/minio/minio-go/pkg/s3signer"
+)
+
+// regRegion matches the region in the credential scope of a V4 signature.
+var regRegion = regexp.MustCompile(`Credential=[^/]+/[0-9]{8}/([^/]+)/s3/aws4_request`)
+
+// SetTransport sets the transport the requests of the client are sent with.
+// When the client has options or several endpoints, its requests are
+// rewritten and signed again before they are handed to base.
+func (c *MinioClient) SetTransport(base http.RoundTripper) {
+	if c.pool == nil && c.Options.Region == "" && c.Options.Lookup == LookupAuto {
+		c.Client.SetCustomTransport(base)
+		return
+	}
+	c.Client.SetCustomTransport(signingTransport{
+		endpoint:        c.Endpoints()[0],
+		opts:            c.Options,
+		accessKeyID:     c.AccesKeyID,
+		secretAccessKey: c.SecretAccessKey,
+		pool:            c.pool,
+		base:            base,
+	})
+}
+
+// signingTransport rewrites the requests of minio-go to the bucket lookup
+// style of the provider and signs them again for its region. minio-go
+// doesn't allow setting the lookup style or the region.
+//
+// minio-go only knows about the first endpoint of a client with several
+// ones, so the requests are also moved to the endpoints of the pool, in the
+// order of its policy, until one of them answers.
+type signingTransport struct {
+	endpoint        string
+	opts            Options
+	accessKeyID     string
+	secretAccessKey string
+	pool            *endpointPool
+	base            http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	auth := req.Header.Get("Authorization")
+	if auth == "" {
+		// Presigned and anonymous requests are passed as they are.
+		return t.base.RoundTrip(req)
+	}
+	if t.pool == nil {
+		return t.send(req, auth, t.endpoint)
+	}
+
+	endpoints := t.pool.candidates()
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil && req.ContentLength != 0 {
+		// The body can't be sent twice.
+		endpoints = endpoints[:1]
+	}
+	var (
+		resp *http.Response
+		err  error
+	)
+	for i, endpoint := range endpoints {
+		if i > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r := *req
+			r.Body = body
+			req = &r
+		}
+		resp, err = t.send(req, auth, endpoint)
+		if err == nil && resp.StatusCode != http.StatusServiceUnavailable {
+			t.pool.mark(endpoint, true)
+			return resp, nil
+		}
+		t.pool.mark(endpoint, false)
+		if resp != nil && i < len(endpoints)-1 {
+			resp.Body.Close()
+		}
+	}
+	return resp, err
+}
+
+// send signs a copy of req for endpoint and sends it.
+func (t signingTransport) send(req *http.Request, auth, endpoint string) (*http.Response, error) {
+	signed := *req
+	u := *req.URL
+	signed.URL = &u
+	signed.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		signed.Header[k] = v
+	}
+	signed.Header.Del("Authorization")
+	if endpoint != t.endpoint {
+		moveHost(&signed, t.endpoint, endpoint)
+	}
+	t.rewrite(&signed, endpoint)
+
+	if strings.HasPrefix(auth, "AWS ") {
+		return t.base.RoundTrip(s3signer.SignV2(signed, t.accessKeyID, t.secretAccessKey))
+	}
+	region := t.opts.Region
+	if match := regRegion.FindStringSubmatch(auth); region == "" && match != nil {
+		region = match[1]
+	}
+	if region == "" {
+		region = defaultRegion
+	}
+	return t.base.RoundTrip(s3signer.SignV4(signed, t.accessKeyID, t.secretAccessKey, region))
+}
+
+// moveHost moves a request addressed to the endpoint from, or to a bucket on
+// it, to the endpoint to.
+func moveHost(req *http.Request, from, to string) {
+	switch {
+	case req.URL.Host == from:
+		req.URL.Host = to
+	case strings.HasSuffix(req.URL.Host, "."+from):
+		req.URL.Host = strings.TrimSuffix(req.URL.Host, from) + to
+	default:
+		return
+	}
+	req.Host = req.URL.Host
+}
+
+// rewrite moves the bucket of a request between its host and its path,
+// according to the lookup style.
+func (t signingTransport) rewrite(req *http.Request, endpoint string) {
+	switch {
+	case t.opts.Lookup == LookupVirtualHost && req.URL.Host == endpoint:
+		parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+		if parts[0] == "" {
+			return
+		}
+		req.URL.Host = parts[0] + "." + endpoint
+		req.URL.Path = "/"
+		if len(parts) == 2 {
+			req.URL.Path += parts[1]
+		}
+	case t.opts.Lookup == LookupPath && strings.HasSuffix(req.URL.Host, "."+endpoint):
+		bucket := strings.TrimSuffix(req.URL.Host, "."+endpoint)
+		req.URL.Host = endpoint
+		req.URL.Path = "/" + bucket + req.URL.Path
+	default:
+		return
+	}
+	req.URL.RawPath = ""
+	req.Host = req.URL.Host
+}
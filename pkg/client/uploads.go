@@ -0,0 +1,36 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// AbortStaleUploads aborts the multipart uploads of the bucket of the client
+// that were initiated more than olderThan ago, and returns how many it
+// aborted. The uploads in progress are left alone, the bucket may be shared
+// with other hosts writing to it.
+func (c *MinioClient) AbortStaleUploads(olderThan time.Duration) (int, error) {
+	if c.BucketName == "" {
+		return 0, fmt.Errorf("no bucket set for aborting uploads")
+	}
+	cutoff := time.Now().Add(-olderThan)
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	aborted := 0
+	for upload := range c.Client.ListIncompleteUploads(c.BucketName, "", true, doneCh) {
+		if upload.Err != nil {
+			return aborted, upload.Err
+		}
+		if !upload.Initiated.Before(cutoff) {
+			continue
+		}
+		query := url.Values{"uploadId": {upload.UploadID}}
+		if _, err := c.bucketRequest("DELETE", c.BucketName, upload.Key, query, nil, nil); err != nil {
+			return aborted, fmt.Errorf("failed to abort upload of %s: %s", upload.Key, err)
+		}
+		aborted++
+	}
+	return aborted, nil
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAbortStaleUploads(t *testing.T) {
+	stale := time.Now().Add(-48 * time.Hour).UTC().Format("2006-01-02T15:04:05.000Z")
+	recent := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+	var aborted []string
+	c, ts := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		_, uploads := query["uploads"]
+		switch {
+		case uploads:
+			fmt.Fprintf(w, `<ListMultipartUploadsResult><Bucket>testbucket</Bucket><IsTruncated>false</IsTruncated>`+
+				`<Upload><Key>logs/old #1?;.bin</Key><UploadId>1</UploadId><Initiated>%s</Initiated></Upload>`+
+				`<Upload><Key>new.bin</Key><UploadId>2</UploadId><Initiated>%s</Initiated></Upload>`+
+				`</ListMultipartUploadsResult>`, stale, recent)
+		case r.Method == "GET" && query.Get("uploadId") != "":
+			fmt.Fprint(w, `<ListPartsResult><IsTruncated>false</IsTruncated></ListPartsResult>`)
+		case r.Method == "DELETE":
+			if !strings.HasPrefix(r.RequestURI, "/testbucket/logs/old%20%231%3F%3B.bin?") {
+				t.Errorf("Expected the key to be escaped, got %s", r.RequestURI)
+			}
+			aborted = append(aborted, r.URL.Path+"?"+query.Get("uploadId"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer ts.Close()
+
+	n, err := c.AbortStaleUploads(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("An error occured while aborting uploads: %s", err)
+	}
+	if n != 1 || len(aborted) != 1 || aborted[0] != "/testbucket/logs/old #1?;.bin?1" {
+		t.Errorf("Expected only the upload of the old file to be aborted, got %v", aborted)
+	}
+}
@@ -0,0 +1,221 @@
+package driver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+const mountsFile = "/proc/mounts"
+
+// VolumeInfo is the state of a volume as exposed through the admin API.
+type VolumeInfo struct {
+	Name       string                 `json:"name"`
+	Mountpoint string                 `json:"mountpoint"`
+	Bucket     string                 `json:"bucket"`
+	Mounted    bool                   `json:"mounted"`
+	MountIDs   []string               `json:"mountIDs"`
+	Status     map[string]interface{} `json:"status,omitempty"`
+}
+
+// Volumes returns the state of all the volumes known to the driver.
+func (d *MinioDriver) Volumes() ([]VolumeInfo, error) {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	mounted, err := readMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []VolumeInfo
+	for name, v := range d.volumes {
+		infos = append(infos, d.volumeInfo(name, v, mounted))
+	}
+	sort.Sort(byName(infos))
+	return infos, nil
+}
+
+// Volume returns the state of a single volume, including its status.
+func (d *MinioDriver) Volume(name string) (VolumeInfo, error) {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	v, exists := d.volumes[name]
+	if !exists {
+		return VolumeInfo{}, newErrVolNotFound(name)
+	}
+	mounted, err := readMounts()
+	if err != nil {
+		return VolumeInfo{}, err
+	}
+	info := d.volumeInfo(name, v, mounted)
+	info.Status = d.volumeStatus(v)
+	return info, nil
+}
+
+// ForceUnmount unmounts a volume even if containers are still using it and
+// forgets about all of its mount IDs, also in the state, so that they aren't
+// mounted again on restart.
+func (d *MinioDriver) ForceUnmount(name string) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	v, exists := d.volumes[name]
+	if !exists {
+		return newErrVolNotFound(name)
+	}
+	glog.V(0).Infof("Force unmounting volume %s used by %d mounts", name, len(v.mounts))
+	if err := d.unmountVolume(v); err != nil {
+		return err
+	}
+	v.mounts = make(map[string]struct{})
+	if err := d.saveState(); err != nil {
+		glog.Warningf("Failed to save state after force unmounting volume %s: %s", name, err)
+	}
+	return nil
+}
+
+// Remount unmounts a volume, if it's mounted, and mounts it again while
+// keeping its mount IDs.
+func (d *MinioDriver) Remount(name string) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	v, exists := d.volumes[name]
+	if !exists {
+		return newErrVolNotFound(name)
+	}
+	mounted, err := readMounts()
+	if err != nil {
+		return err
+	}
+	glog.V(0).Infof("Remounting volume %s", name)
+	if mounted[v.mountpoint] {
+		if err := d.unmountVolume(v); err != nil {
+			return err
+		}
+	}
+	return d.mountVolume(v)
+}
+
+// ReleaseMount drops a stale mount ID of a volume, for example one left
+// behind by a container that is gone. The volume is unmounted when its last
+// mount ID is released. The mount ID is also dropped from the state.
+func (d *MinioDriver) ReleaseMount(name, id string) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	v, exists := d.volumes[name]
+	if !exists {
+		return newErrVolNotFound(name)
+	}
+	if _, ok := v.mounts[id]; !ok {
+		return fmt.Errorf("volume %s has no mount with ID %s", name, id)
+	}
+	delete(v.mounts, id)
+	if len(v.mounts) == 0 {
+		if err := d.unmountVolume(v); err != nil {
+			v.mounts[id] = struct{}{}
+			return err
+		}
+	}
+	glog.V(0).Infof("Released mount %s of volume %s", id, name)
+	if err := d.saveState(); err != nil {
+		glog.Warningf("Failed to save state after releasing mount %s of volume %s: %s", id, name, err)
+	}
+	return nil
+}
+
+// Reconcile compares the mount IDs of every volume with the mount table and
+// fixes the differences: volumes in use that are not mounted are mounted and
+// volumes that are mounted but not in use are unmounted. It returns the
+// actions that were taken.
+func (d *MinioDriver) Reconcile() ([]string, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	mounted, err := readMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		actions []string
+		errs    []string
+	)
+	for name, v := range d.volumes {
+		switch {
+		case len(v.mounts) > 0 && !mounted[v.mountpoint]:
+			if err := d.mountVolume(v); err != nil {
+				errs = append(errs, fmt.Sprintf("mounting %s: %s", name, err))
+				continue
+			}
+			actions = append(actions, fmt.Sprintf("mounted %s", name))
+		case len(v.mounts) == 0 && mounted[v.mountpoint]:
+			if err := d.unmountVolume(v); err != nil {
+				errs = append(errs, fmt.Sprintf("unmounting %s: %s", name, err))
+				continue
+			}
+			actions = append(actions, fmt.Sprintf("unmounted %s", name))
+		}
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		glog.V(0).Infof("Reconcile: %s", action)
+	}
+	if len(errs) > 0 {
+		return actions, fmt.Errorf("reconcile failed: %s", strings.Join(errs, ", "))
+	}
+	return actions, nil
+}
+
+func (d *MinioDriver) volumeInfo(name string, v *minioVolume, mounted map[string]bool) VolumeInfo {
+	ids := make([]string, 0, len(v.mounts))
+	for id := range v.mounts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return VolumeInfo{
+		Name:       name,
+		Mountpoint: v.mountpoint,
+		Bucket:     v.bucketName,
+		Mounted:    mounted[v.mountpoint],
+		MountIDs:   ids,
+	}
+}
+
+// readMounts returns the mount points currently found in the mount table.
+func readMounts() (map[string]bool, error) {
+	fh, err := os.Open(mountsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return parseMounts(fh)
+}
+
+// parseMounts parses a mount table in the format of /proc/mounts.
+func parseMounts(r io.Reader) (map[string]bool, error) {
+	mounted := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		mounted[strings.Replace(fields[1], `\040`, " ", -1)] = true
+	}
+	return mounted, scanner.Err()
+}
+
+type byName []VolumeInfo
+
+func (v byName) Len() int           { return len(v) }
+func (v byName) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v byName) Less(i, j int) bool { return v[i].Name < v[j].Name }
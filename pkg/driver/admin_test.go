@@ -0,0 +1,75 @@
+package driver
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+func TestParseMounts(t *testing.T) {
+	table := `proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+minfs /mnt/miniovol-0000002a fuse.minfs rw,nosuid,nodev 0 0
+minfs /mnt/with\040space fuse.minfs rw 0 0
+`
+	mounted, err := parseMounts(strings.NewReader(table))
+	if err != nil {
+		t.Fatalf("An error occured while parsing the mount table: %s", err)
+	}
+	expected := map[string]bool{
+		"/proc":                  true,
+		"/mnt/miniovol-0000002a": true,
+		"/mnt/with space":        true,
+	}
+	if !reflect.DeepEqual(mounted, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, mounted)
+	}
+}
+
+func TestConfigRedacted(t *testing.T) {
+	cfg := Config{AccessKey: "access", SecretKey: "secret"}
+	redactedCfg := cfg.Redacted()
+	if redactedCfg.SecretKey != redacted || redactedCfg.AccessKey != "access" {
+		t.Errorf("Expected only the secret key to be redacted, got %#v", redactedCfg)
+	}
+	if cfg.SecretKey != "secret" {
+		t.Errorf("Expected the original config to be untouched, got %#v", cfg)
+	}
+}
+
+func TestReleaseMount(t *testing.T) {
+	d, dir := newStateDriver(t)
+	defer os.RemoveAll(dir)
+	c, err := client.NewMinioClient("minio:9000", "access", "secret", "testbucket", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newVolume("test", "/mnt/test", "testbucket")
+	v.c = c
+	v.mounts["a"] = struct{}{}
+	v.mounts["b"] = struct{}{}
+	d.volumes["test"] = v
+
+	if err := d.ReleaseMount("test", "a"); err != nil {
+		t.Fatalf("An error occured while releasing the mount: %s", err)
+	}
+	if _, ok := v.mounts["a"]; ok || len(v.mounts) != 1 {
+		t.Errorf("Expected only mount b to be left, got %#v", v.mounts)
+	}
+	restored := NewMinioDriver(nil, false)
+	restored.SetStateFile(d.statePath)
+	if err := restored.LoadState(); err != nil {
+		t.Fatalf("An error occured while loading the state: %s", err)
+	}
+	if rv := restored.volumes["test"]; rv == nil || !reflect.DeepEqual(rv.mounts, v.mounts) {
+		t.Errorf("Expected the released mount to be dropped from the state, got %#v", rv)
+	}
+	if err := d.ReleaseMount("test", "c"); err == nil {
+		t.Errorf("Expected releasing an unknown mount to fail")
+	}
+	if err := d.ReleaseMount("missing", "b"); err == nil {
+		t.Errorf("Expected releasing a mount of an unknown volume to fail")
+	}
+}
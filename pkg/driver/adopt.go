@@ -0,0 +1,224 @@
+package driver
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+// Adopt registers an existing bucket as the volume name. The options select
+// the endpoint of the bucket and are completed from the configuration like
+// the ones of Create. The bucket is left as it is, it's neither configured
+// nor removed with the volume. The driver is only locked once the bucket was
+// found.
+func (d *MinioDriver) Adopt(name, bucket string, options map[string]string) error {
+	d.m.RLock()
+	options, err := d.cfg.withDefaults(options)
+	d.m.RUnlock()
+	if err != nil {
+		return err
+	}
+	if options, err = withSecretKeyFile(options); err != nil {
+		return err
+	}
+	c, err := newEndpointClient(options)
+	if err != nil {
+		return err
+	}
+	exists, err := c.Client.BucketExists(bucket)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket %s: %s", bucket, err)
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s doesn't exist on %s", bucket, c.ServerURI)
+	}
+
+	d.m.Lock()
+	defer d.m.Unlock()
+	if err := d.adopt(name, bucket, c, options); err != nil {
+		return err
+	}
+	if err := d.saveState(); err != nil {
+		glog.Warningf("Failed to save state after adopting bucket %s: %s", bucket, err)
+	}
+	return nil
+}
+
+// Discover registers the buckets of the server and of the profiles of the
+// configuration that match its autoDiscover settings as volumes named after
+// them. It returns the names of the new volumes. The buckets are listed
+// without holding the driver lock.
+func (d *MinioDriver) Discover() ([]string, error) {
+	d.m.RLock()
+	auto := d.cfg.AutoDiscover
+	sources := d.discoverySources()
+	d.m.RUnlock()
+	if auto == nil {
+		return nil, fmt.Errorf("autoDiscover is not configured")
+	}
+
+	var (
+		found []discovered
+		errs  []string
+	)
+	for _, source := range sources {
+		buckets, err := d.discover(*auto, source)
+		found = append(found, buckets...)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	d.m.Lock()
+	defer d.m.Unlock()
+	var adopted []string
+	for _, b := range found {
+		if d.bucketVolume(b.c.ServerURI, b.bucket) != "" {
+			continue
+		}
+		if err := d.adopt(b.bucket, b.bucket, b.c, b.options); err != nil {
+			glog.Warningf("Failed to adopt bucket %s: %s", b.bucket, err)
+			continue
+		}
+		adopted = append(adopted, b.bucket)
+	}
+	sort.Strings(adopted)
+	if len(adopted) > 0 {
+		if err := d.saveState(); err != nil {
+			glog.Warningf("Failed to save state after discovering volumes: %s", err)
+		}
+	}
+	if len(errs) > 0 {
+		return adopted, fmt.Errorf("discover failed: %s", strings.Join(errs, ", "))
+	}
+	return adopted, nil
+}
+
+// discovered is a bucket found by Discover, along with the client and the
+// options of its source.
+type discovered struct {
+	bucket  string
+	c       *client.MinioClient
+	options map[string]string
+}
+
+// discoverySources returns the options of the server of the configuration,
+// if any, followed by the ones of each profile. The caller must hold the
+// driver lock, for reading at least.
+func (d *MinioDriver) discoverySources() []map[string]string {
+	var sources []map[string]string
+	if d.cfg.Server != "" {
+		server := Config{
+			Server:    d.cfg.Server,
+			AccessKey: d.cfg.AccessKey,
+			SecretKey: d.cfg.SecretKey,
+			Secure:    d.cfg.Secure,
+		}
+		options, _ := server.withDefaults(map[string]string{})
+		sources = append(sources, options)
+	}
+	names := make([]string, 0, len(d.cfg.Profiles))
+	for name := range d.cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		options, _ := d.cfg.withDefaults(map[string]string{"profile": name})
+		sources = append(sources, options)
+	}
+	return sources
+}
+
+// discover returns the buckets of one source that match auto and aren't
+// volumes yet. The driver lock must not be held, it's only taken to check
+// whether a bucket is a volume already.
+func (d *MinioDriver) discover(auto AutoDiscover, options map[string]string) ([]discovered, error) {
+	options, err := withSecretKeyFile(options)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newEndpointClient(options)
+	if err != nil {
+		return nil, err
+	}
+	buckets, err := c.Client.ListBuckets()
+	if err != nil {
+		return nil, fmt.Errorf("listing buckets of %s: %s", c.ServerURI, err)
+	}
+
+	var found []discovered
+	for _, bucket := range buckets {
+		if !strings.HasPrefix(bucket.Name, auto.Prefix) {
+			continue
+		}
+		d.m.RLock()
+		known := d.bucketVolume(c.ServerURI, bucket.Name) != ""
+		d.m.RUnlock()
+		if known {
+			continue
+		}
+		tags := map[string]string{}
+		if auto.Tag != "" {
+			if tags, err = c.BucketTags(bucket.Name); err != nil {
+				glog.Warningf("Failed to read the tags of bucket %s: %s", bucket.Name, err)
+				continue
+			}
+		}
+		if auto.matches(bucket.Name, tags) {
+			found = append(found, discovered{bucket: bucket.Name, c: c, options: options})
+		}
+	}
+	return found, nil
+}
+
+// adopt registers bucket as the volume name, with a copy of c. The caller
+// must hold the driver lock.
+func (d *MinioDriver) adopt(name, bucket string, c *client.MinioClient, options map[string]string) error {
+	if _, exists := d.volumes[name]; exists {
+		return fmt.Errorf("volume %s already exists", name)
+	}
+	if other := d.bucketVolume(c.ServerURI, bucket); other != "" {
+		return fmt.Errorf("bucket %s is already used by volume %s", bucket, other)
+	}
+
+	mountpoint := filepath.Join("/mnt", createName(volumePrefix))
+	if err := d.createVolumeMount(mountpoint); err != nil {
+		return err
+	}
+	v := newVolume(createName(volumePrefix), mountpoint, bucket)
+	vc := *c
+	vc.BucketName = bucket
+	v.c = &vc
+	v.profile = options["profile"]
+	v.secretKeyFile = options["secretKeyFile"]
+	v.credentialsUpdated = time.Now().UTC()
+	d.volumes[name] = v
+	glog.V(0).Infof("Adopted bucket %s of %s as volume %s", bucket, c.ServerURI, name)
+	return nil
+}
+
+// bucketVolume returns the name of the volume of a bucket on server, or an
+// empty string. The caller must hold the driver lock.
+func (d *MinioDriver) bucketVolume(server, bucket string) string {
+	for name, v := range d.volumes {
+		if v.bucketName == bucket && v.c.ServerURI == server {
+			return name
+		}
+	}
+	return ""
+}
+
+// newEndpointClient returns a client for the endpoint of the volume options.
+func newEndpointClient(options map[string]string) (*client.MinioClient, error) {
+	e, err := endpointFromOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewMinioClientWithOptions(e.server, e.accessKey, e.secretKey, "", e.secure, e.opts)
+}
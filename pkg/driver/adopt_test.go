@@ -0,0 +1,157 @@
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/go-plugins-helpers/volume"
+)
+
+// newBucketServer serves a listing of the buckets data-1, data-2 and other,
+// of which only data-1 is tagged.
+func newBucketServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, tagging := r.URL.Query()["tagging"]
+		_, location := r.URL.Query()["location"]
+		bucket := strings.Trim(r.URL.Path, "/")
+		switch {
+		case location && bucket != "missing":
+			w.Write([]byte(`<LocationConstraint>us-east-1</LocationConstraint>`))
+		case bucket == "":
+			w.Write([]byte(`<ListAllMyBucketsResult><Buckets>` +
+				`<Bucket><Name>data-1</Name><CreationDate>2017-01-01T00:00:00.000Z</CreationDate></Bucket>` +
+				`<Bucket><Name>data-2</Name><CreationDate>2017-01-01T00:00:00.000Z</CreationDate></Bucket>` +
+				`<Bucket><Name>other</Name><CreationDate>2017-01-01T00:00:00.000Z</CreationDate></Bucket>` +
+				`</Buckets></ListAllMyBucketsResult>`))
+		case tagging && bucket == "data-1":
+			w.Write([]byte(`<Tagging><TagSet><Tag><Key>miniovol</Key><Value>yes</Value></Tag></TagSet></Tagging>`))
+		case tagging:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchTagSet</Code><Message>The TagSet does not exist</Message></Error>`))
+		case r.Method == "HEAD" && bucket != "missing":
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func removeMountpoints(d *MinioDriver) {
+	for _, v := range d.volumes {
+		os.RemoveAll(v.mountpoint)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	ts := newBucketServer()
+	defer ts.Close()
+	server := strings.TrimPrefix(ts.URL, "http://")
+
+	d := NewMinioDriver(nil, false)
+	defer removeMountpoints(d)
+	if _, err := d.Discover(); err == nil {
+		t.Errorf("Expected Discover to fail without autoDiscover")
+	}
+
+	d.SetConfig(Config{
+		Server:       server,
+		AccessKey:    "access",
+		SecretKey:    "secret",
+		AutoDiscover: &AutoDiscover{Prefix: "data-", Tag: "miniovol=yes"},
+	}, "")
+	adopted, err := d.Discover()
+	if err != nil {
+		t.Fatalf("An error occured while discovering volumes: %s", err)
+	}
+	if !reflect.DeepEqual(adopted, []string{"data-1"}) {
+		t.Errorf("Expected data-1 to be adopted, got %v", adopted)
+	}
+	if v := d.volumes["data-1"]; v == nil || v.bucketName != "data-1" || v.c.BucketName != "data-1" {
+		t.Errorf("Expected volume data-1 for bucket data-1, got %#v", v)
+	}
+	if adopted, err := d.Discover(); err != nil || len(adopted) != 0 {
+		t.Errorf("Expected no new volume, got %v and %v", adopted, err)
+	}
+
+	if err := d.Adopt("manual", "other", map[string]string{}); err != nil {
+		t.Errorf("An error occured while adopting bucket other: %s", err)
+	}
+	if err := d.Adopt("again", "other", map[string]string{}); err == nil {
+		t.Errorf("Expected a bucket to be adopted once")
+	}
+	if err := d.Adopt("missing", "missing", map[string]string{}); err == nil {
+		t.Errorf("Expected a missing bucket not to be adopted")
+	}
+}
+
+func TestAutoDiscoverMatches(t *testing.T) {
+	tags := map[string]string{"team": "ml"}
+	tests := []struct {
+		auto    AutoDiscover
+		bucket  string
+		matches bool
+	}{
+		{AutoDiscover{Prefix: "ml-"}, "ml-data", true},
+		{AutoDiscover{Prefix: "ml-"}, "data", false},
+		{AutoDiscover{Tag: "team"}, "data", true},
+		{AutoDiscover{Tag: "team=ml"}, "data", true},
+		{AutoDiscover{Tag: "team=web"}, "data", false},
+		{AutoDiscover{Prefix: "data", Tag: "owner"}, "data", false},
+	}
+	for _, test := range tests {
+		if matches := test.auto.matches(test.bucket, tags); matches != test.matches {
+			t.Errorf("Expected %#v to match %s: %t", test.auto, test.bucket, test.matches)
+		}
+	}
+}
+
+func TestDiscoverWithoutLock(t *testing.T) {
+	buckets := newBucketServer()
+	defer buckets.Close()
+	listing := make(chan struct{})
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			close(listing)
+			<-release
+		}
+		buckets.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	d := NewMinioDriver(nil, false)
+	defer removeMountpoints(d)
+	d.SetConfig(Config{
+		Server:       strings.TrimPrefix(ts.URL, "http://"),
+		AccessKey:    "access",
+		SecretKey:    "secret",
+		AutoDiscover: &AutoDiscover{Prefix: "data-", Tag: "miniovol=yes"},
+	}, "")
+	discovered := make(chan error, 1)
+	go func() {
+		_, err := d.Discover()
+		discovered <- err
+	}()
+
+	<-listing
+	listed := make(chan volume.Response, 1)
+	go func() {
+		listed <- d.List(volume.Request{})
+	}()
+	select {
+	case <-listed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected List not to wait for the buckets to be listed")
+	}
+	close(release)
+	if err := <-discovered; err != nil {
+		t.Fatalf("An error occured while discovering volumes: %s", err)
+	}
+	if _, ok := d.volumes["data-1"]; !ok {
+		t.Errorf("Expected data-1 to be adopted, got %#v", d.volumes)
+	}
+}
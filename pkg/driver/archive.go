@@ -0,0 +1,77 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/docker/go-plugins-helpers/volume"
+	"github.com/golang/glog"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+// ExportVolume writes the objects of a volume found under prefix to w as a
+// tar archive, compressed according to compression, followed by a manifest
+// with their checksums. The driver isn't locked while the archive is
+// written.
+func (d *MinioDriver) ExportVolume(name, prefix, compression string, w io.Writer) (client.Manifest, error) {
+	c, err := d.volumeClient(name)
+	if err != nil {
+		return client.Manifest{}, err
+	}
+	manifest, err := c.Export(w, prefix, compression)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to export volume %s: %s", name, err)
+	}
+	glog.V(0).Infof("Exported %d objects of volume %s", len(manifest.Objects), name)
+	return manifest, nil
+}
+
+// ImportVolume uploads the entries of a tar archive to a volume. The volume
+// is created with options when it doesn't exist, otherwise the entries are
+// added to it, so that an import that failed can be run again. When the
+// archive comes from ExportVolume, the imported objects are checked against
+// its manifest. SSE-C volumes are refused, like the seeds.
+func (d *MinioDriver) ImportVolume(name string, r io.Reader, options map[string]string) (client.Manifest, error) {
+	d.m.RLock()
+	_, exists := d.volumes[name]
+	d.m.RUnlock()
+
+	if !exists {
+		if options["sse"] == client.SSEC {
+			return client.Manifest{}, fmt.Errorf("can't import into volume %s with sse=c, large files would be uploaded without the customer key", name)
+		}
+		if resp := d.Create(volume.Request{Name: name, Options: options}); resp.Err != "" {
+			return client.Manifest{}, errors.New(resp.Err)
+		}
+		if err := d.SaveState(); err != nil {
+			glog.Warningf("Failed to save state after creating volume %s: %s", name, err)
+		}
+	} else if len(options) > 0 {
+		return client.Manifest{}, fmt.Errorf("volume %s already exists, options are only used to create it", name)
+	}
+
+	c, err := d.volumeClient(name)
+	if err != nil {
+		return client.Manifest{}, err
+	}
+	manifest, err := c.Import(r)
+	if err != nil {
+		return manifest, fmt.Errorf("failed to import into volume %s: %s", name, err)
+	}
+	glog.V(0).Infof("Imported %d objects into volume %s", len(manifest.Objects), name)
+	return manifest, nil
+}
+
+// volumeClient returns the client of a volume.
+func (d *MinioDriver) volumeClient(name string) (*client.MinioClient, error) {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	v, exists := d.volumes[name]
+	if !exists {
+		return nil, newErrVolNotFound(name)
+	}
+	return v.c, nil
+}
@@ -0,0 +1,46 @@
+package driver
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+func TestExportImportVolume(t *testing.T) {
+	d := NewMinioDriver(nil, false)
+	if _, err := d.ExportVolume("missing", "", "", ioutil.Discard); err == nil {
+		t.Errorf("Expected the export of a missing volume to fail")
+	} else if _, ok := err.(VolumeError); !ok {
+		t.Errorf("Expected a VolumeError, got %#v", err)
+	}
+
+	c, err := client.NewMinioClient("localhost:9000", "access", "secret", "testbucket", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newVolume("miniovol-test", "/mnt/miniovol-test", "testbucket")
+	v.c = c
+	d.volumes["test"] = v
+
+	_, err = d.ImportVolume("test", strings.NewReader(""), map[string]string{"profile": "archive"})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected options to be refused for an existing volume, got %v", err)
+	}
+	if _, err := d.ExportVolume("test", "", "zstd", ioutil.Discard); err == nil {
+		t.Errorf("Expected zstd compression to be refused")
+	}
+
+	c.Encryption = &client.Encryption{Mode: client.SSEC, CustomerKey: make([]byte, 32)}
+	if _, err := d.ImportVolume("test", strings.NewReader(""), nil); err == nil || !strings.Contains(err.Error(), "SSE-C") {
+		t.Errorf("Expected imports into SSE-C volumes to be refused, got %v", err)
+	}
+	_, err = d.ImportVolume("new", strings.NewReader(""), map[string]string{"sse": "c"})
+	if err == nil || !strings.Contains(err.Error(), "sse=c") {
+		t.Errorf("Expected SSE-C volumes not to be created for imports, got %v", err)
+	}
+	if _, exists := d.volumes["new"]; exists {
+		t.Errorf("Expected the refused volume not to be created")
+	}
+}
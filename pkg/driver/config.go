@@ -0,0 +1,316 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+const redacted = "<redacted>"
+
+// Environment variables that override the configuration file.
+const (
+	envServer    = "MINIOVOL_SERVER"
+	envAccessKey = "MINIOVOL_ACCESS_KEY"
+	envSecretKey = "MINIOVOL_SECRET_KEY"
+	envSecure    = "MINIOVOL_SECURE"
+)
+
+// Config is the driver wide configuration. Its values are used as defaults
+// for the options that are not passed when a volume is created.
+type Config struct {
+	Server    string `json:"server,omitempty"`
+	AccessKey string `json:"accessKey,omitempty"`
+	SecretKey string `json:"secretKey,omitempty"`
+	Secure    bool   `json:"secure"`
+
+	// Profiles are named sets of defaults, selected with the profile
+	// option. DefaultProfile is used when no profile is passed.
+	Profiles       map[string]Profile `json:"profiles,omitempty"`
+	DefaultProfile string             `json:"defaultProfile,omitempty"`
+
+	// AutoDiscover, when set, registers the matching buckets of the server
+	// and of the profiles as volumes.
+	AutoDiscover *AutoDiscover `json:"autoDiscover,omitempty"`
+}
+
+// AutoDiscover selects the buckets that are registered as volumes by
+// Discover. A bucket must match both the prefix and the tag, when they are
+// set.
+type AutoDiscover struct {
+	Prefix string `json:"prefix,omitempty"`
+	// Tag is either key=value, or key to match any value.
+	Tag string `json:"tag,omitempty"`
+}
+
+// matches reports whether a bucket with the given tags is selected.
+func (a AutoDiscover) matches(bucket string, tags map[string]string) bool {
+	if !strings.HasPrefix(bucket, a.Prefix) {
+		return false
+	}
+	if a.Tag == "" {
+		return true
+	}
+	kv := strings.SplitN(a.Tag, "=", 2)
+	value, ok := tags[kv[0]]
+	return ok && (len(kv) == 1 || value == kv[1])
+}
+
+// Profile is a named endpoint along with the credentials and settings used
+// to reach it.
+type Profile struct {
+	Server           string `json:"server"`
+	AccessKey        string `json:"accessKey,omitempty"`
+	SecretKey        string `json:"secretKey,omitempty"`
+	SecretKeyFile    string `json:"secretKeyFile,omitempty"`
+	Secure           bool   `json:"secure"`
+	Region           string `json:"region,omitempty"`
+	PathStyle        *bool  `json:"pathStyle,omitempty"`
+	SignatureVersion string `json:"signatureVersion,omitempty"`
+	Failover         string `json:"failover,omitempty"`
+	Backend          string `json:"backend,omitempty"`
+}
+
+// options returns the profile as volume options.
+func (p Profile) options() map[string]string {
+	opts := map[string]string{
+		"server":           p.Server,
+		"accessKey":        p.AccessKey,
+		"secretKey":        p.SecretKey,
+		"secretKeyFile":    p.SecretKeyFile,
+		"region":           p.Region,
+		"signatureVersion": p.SignatureVersion,
+		"failover":         p.Failover,
+		"backend":          p.Backend,
+	}
+	if p.Secure {
+		opts["secure"] = "true"
+	}
+	if p.PathStyle != nil {
+		opts["pathStyle"] = strconv.FormatBool(*p.PathStyle)
+	}
+	return opts
+}
+
+// validate checks the profiles of the configuration.
+func (c Config) validate() error {
+	for name, p := range c.Profiles {
+		if p.Server == "" {
+			return fmt.Errorf("profile %s has no server", name)
+		}
+		if err := checkBackendName(p.Backend); err != nil {
+			return fmt.Errorf("profile %s: %s", name, err)
+		}
+	}
+	if _, ok := c.Profiles[c.DefaultProfile]; c.DefaultProfile != "" && !ok {
+		return fmt.Errorf("default profile %s is not defined", c.DefaultProfile)
+	}
+	if c.AutoDiscover != nil && c.AutoDiscover.Prefix == "" && c.AutoDiscover.Tag == "" {
+		return fmt.Errorf("autoDiscover needs a prefix or a tag")
+	}
+	return nil
+}
+
+// LoadConfig reads the configuration from the JSON file at path, if it
+// exists, and then applies the overrides found in the environment.
+func LoadConfig(path string) (Config, error) {
+	cfg := Config{}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return cfg, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				return cfg, fmt.Errorf("failed to parse config %s: %s", path, err)
+			}
+			if err := cfg.validate(); err != nil {
+				return cfg, fmt.Errorf("invalid config %s: %s", path, err)
+			}
+		}
+	}
+
+	if server := os.Getenv(envServer); server != "" {
+		cfg.Server = server
+	}
+	if accessKey := os.Getenv(envAccessKey); accessKey != "" {
+		cfg.AccessKey = accessKey
+	}
+	if secretKey := os.Getenv(envSecretKey); secretKey != "" {
+		cfg.SecretKey = secretKey
+	}
+	if secure := os.Getenv(envSecure); secure != "" {
+		s, err := strconv.ParseBool(secure)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid %s: %s", envSecure, err)
+		}
+		cfg.Secure = s
+	}
+	return cfg, nil
+}
+
+// Redacted returns a copy of the configuration that is safe to print.
+func (c Config) Redacted() Config {
+	if c.SecretKey != "" {
+		c.SecretKey = redacted
+	}
+	c.Profiles = redactProfiles(c.Profiles)
+	return c
+}
+
+// redactProfiles returns a copy of profiles without their secret keys.
+func redactProfiles(profiles map[string]Profile) map[string]Profile {
+	if profiles == nil {
+		return nil
+	}
+	redactedProfiles := make(map[string]Profile, len(profiles))
+	for name, p := range profiles {
+		if p.SecretKey != "" {
+			p.SecretKey = redacted
+		}
+		redactedProfiles[name] = p
+	}
+	return redactedProfiles
+}
+
+// withDefaults returns the volume options completed with the values of the
+// profile passed with the profile option, or of the default profile, for the
+// options that are not set. Without a profile, the values of the
+// configuration are used.
+func (c Config) withDefaults(opts map[string]string) (map[string]string, error) {
+	defaults := map[string]string{
+		"server":    c.Server,
+		"accessKey": c.AccessKey,
+		"secretKey": c.SecretKey,
+	}
+	if c.Secure {
+		defaults["secure"] = "true"
+	}
+	name, err := checkParam("profile", opts)
+	if err != nil {
+		name = c.DefaultProfile
+	}
+	if name != "" {
+		p, ok := c.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %s", name)
+		}
+		defaults = p.options()
+		opts = withOption(opts, "profile", name)
+		// A secret key passed with the volume takes precedence over the
+		// key file of the profile.
+		if _, err := checkParam("secretKey", opts); err == nil {
+			delete(defaults, "secretKeyFile")
+		}
+	}
+	for param, value := range defaults {
+		if _, err := checkParam(param, opts); err != nil && value != "" {
+			opts = withOption(opts, param, value)
+		}
+	}
+	return opts, nil
+}
+
+// SetConfig sets the configuration of the driver and the path it is reloaded
+// from.
+func (d *MinioDriver) SetConfig(cfg Config, path string) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	d.cfg = cfg
+	d.cfgPath = path
+}
+
+// Config returns the effective configuration of the driver with the secrets
+// redacted.
+func (d *MinioDriver) Config() Config {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	return d.cfg.Redacted()
+}
+
+// Reload reads the configuration again from the file and the environment and
+// swaps in a client for the new endpoint and credentials. The new
+// configuration only applies to the volumes created afterwards, existing
+// volumes and their mounts keep using the client they were created with.
+func (d *MinioDriver) Reload() (Config, error) {
+	d.m.RLock()
+	path := d.cfgPath
+	d.m.RUnlock()
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		glog.Warningf("Failed to reload config from %s: %s", path, err)
+		return d.Config(), err
+	}
+	var c *client.MinioClient
+	if cfg.Server != "" {
+		c, err = client.NewMinioClient(cfg.Server, cfg.AccessKey, cfg.SecretKey, "", cfg.Secure)
+		if err != nil {
+			glog.Warningf("Failed to reload config from %s: %s", path, err)
+			return d.Config(), err
+		}
+	}
+
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	changed := configChanges(d.cfg, cfg)
+	d.cfg = cfg
+	d.c = c
+	if len(changed) == 0 {
+		glog.V(0).Infof("Reloaded config from %s, nothing changed", path)
+	} else {
+		glog.V(0).Infof("Reloaded config from %s, changed: %s", path, strings.Join(changed, ", "))
+	}
+	return d.cfg.Redacted(), nil
+}
+
+// configChanges returns the names of the settings that differ between two
+// configurations, without their values.
+func configChanges(old, cfg Config) []string {
+	var changed []string
+	if old.Server != cfg.Server {
+		changed = append(changed, "server")
+	}
+	if old.AccessKey != cfg.AccessKey {
+		changed = append(changed, "accessKey")
+	}
+	if old.SecretKey != cfg.SecretKey {
+		changed = append(changed, "secretKey")
+	}
+	if old.Secure != cfg.Secure {
+		changed = append(changed, "secure")
+	}
+	if !reflect.DeepEqual(old.Profiles, cfg.Profiles) {
+		changed = append(changed, "profiles")
+	}
+	if old.DefaultProfile != cfg.DefaultProfile {
+		changed = append(changed, "defaultProfile")
+	}
+	if !reflect.DeepEqual(old.AutoDiscover, cfg.AutoDiscover) {
+		changed = append(changed, "autoDiscover")
+	}
+	return changed
+}
+
+// Profiles returns the profiles of the driver with the secrets redacted.
+func (d *MinioDriver) Profiles() map[string]Profile {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	profiles := redactProfiles(d.cfg.Profiles)
+	if profiles == nil {
+		profiles = make(map[string]Profile)
+	}
+	return profiles
+}
@@ -0,0 +1,212 @@
+package driver
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+func TestLoadConfig(t *testing.T) {
+	fh, err := ioutil.TempFile("", "miniovol-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fh.Name())
+	fh.WriteString(`{"server":"file:9000","accessKey":"fileKey","secretKey":"fileSecret"}`)
+	fh.Close()
+
+	os.Setenv(envServer, "env:9000")
+	os.Setenv(envSecure, "true")
+	defer os.Unsetenv(envServer)
+	defer os.Unsetenv(envSecure)
+
+	cfg, err := LoadConfig(fh.Name())
+	if err != nil {
+		t.Fatalf("An error occured while loading the config: %s", err)
+	}
+	expected := Config{
+		Server:    "env:9000",
+		AccessKey: "fileKey",
+		SecretKey: "fileSecret",
+		Secure:    true,
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, cfg)
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	cfg, err := LoadConfig("/nonexistent/miniovol.json")
+	if err != nil {
+		t.Errorf("Expected a missing config file to be ignored, got %s", err)
+	}
+	if !reflect.DeepEqual(cfg, Config{}) {
+		t.Errorf("Expected an empty config, got %#v", cfg)
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	cfg := Config{Server: "default:9000", AccessKey: "defaultKey", SecretKey: "defaultSecret"}
+	opts, err := cfg.withDefaults(map[string]string{"server": "other:9000"})
+	if err != nil {
+		t.Fatalf("An error occured while applying the defaults: %s", err)
+	}
+	if opts["server"] != "other:9000" || opts["accessKey"] != "defaultKey" || opts["secretKey"] != "defaultSecret" {
+		t.Errorf("Expected defaults for the missing options only, got %#v", opts)
+	}
+	if _, ok := opts["secure"]; ok {
+		t.Errorf("Expected secure not to be set, got %#v", opts)
+	}
+}
+
+func TestWithDefaultsProfile(t *testing.T) {
+	pathStyle := false
+	cfg := Config{
+		Server:    "default:9000",
+		AccessKey: "defaultKey",
+		SecretKey: "defaultSecret",
+		Profiles: map[string]Profile{
+			"archive": {
+				Server:        "s3.eu-west-1.amazonaws.com",
+				AccessKey:     "archiveKey",
+				SecretKeyFile: "/run/secrets/archive",
+				Secure:        true,
+				Region:        "eu-west-1",
+				PathStyle:     &pathStyle,
+			},
+			"local": {Server: "local:9000", AccessKey: "localKey", SecretKey: "localSecret"},
+		},
+	}
+
+	opts, err := cfg.withDefaults(map[string]string{"profile": "archive", "region": "eu-west-2"})
+	if err != nil {
+		t.Fatalf("An error occured while applying the profile: %s", err)
+	}
+	expected := map[string]string{
+		"profile":       "archive",
+		"server":        "s3.eu-west-1.amazonaws.com",
+		"accessKey":     "archiveKey",
+		"secretKeyFile": "/run/secrets/archive",
+		"secure":        "true",
+		"region":        "eu-west-2",
+		"pathStyle":     "false",
+	}
+	if !reflect.DeepEqual(opts, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, opts)
+	}
+
+	opts, err = cfg.withDefaults(map[string]string{"profile": "archive", "secretKey": "given"})
+	if err != nil {
+		t.Fatalf("An error occured while applying the profile: %s", err)
+	}
+	if _, ok := opts["secretKeyFile"]; ok || opts["secretKey"] != "given" {
+		t.Errorf("Expected the given secret key to replace the key file, got %#v", opts)
+	}
+
+	cfg.DefaultProfile = "local"
+	opts, err = cfg.withDefaults(map[string]string{})
+	if err != nil {
+		t.Fatalf("An error occured while applying the default profile: %s", err)
+	}
+	if opts["profile"] != "local" || opts["server"] != "local:9000" || opts["secretKey"] != "localSecret" {
+		t.Errorf("Expected the default profile to be used, got %#v", opts)
+	}
+
+	if _, err := cfg.withDefaults(map[string]string{"profile": "missing"}); err == nil {
+		t.Errorf("Expected an unknown profile to fail")
+	}
+}
+
+func TestLoadConfigProfiles(t *testing.T) {
+	tests := map[string]bool{
+		`{"profiles":{"archive":{"server":"archive:9000","secretKey":"s"}},"defaultProfile":"archive"}`: true,
+		`{"profiles":{"archive":{"accessKey":"k"}}}`:                                                    false,
+		`{"profiles":{"archive":{"server":"archive:9000","backend":"fuse"}}}`:                           false,
+		`{"defaultProfile":"missing"}`:                                                                  false,
+	}
+	for content, valid := range tests {
+		fh, err := ioutil.TempFile("", "miniovol-config-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fh.WriteString(content)
+		fh.Close()
+		cfg, err := LoadConfig(fh.Name())
+		os.Remove(fh.Name())
+		if (err == nil) != valid {
+			t.Errorf("Expected %s to be valid: %t, got %v", content, valid, err)
+		}
+		if valid && cfg.Redacted().Profiles["archive"].SecretKey != redacted {
+			t.Errorf("Expected the secret key of the profile to be redacted, got %#v", cfg.Redacted())
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	fh, err := ioutil.TempFile("", "miniovol-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fh.Name())
+	fh.WriteString(`{"server":"new:9000","accessKey":"newKey","secretKey":"newSecret"}`)
+	fh.Close()
+
+	old, err := client.NewMinioClient("old:9000", "oldKey", "oldSecret", "bucket", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewMinioDriver(old, false)
+	d.SetConfig(Config{Server: "old:9000", AccessKey: "oldKey", SecretKey: "oldSecret"}, fh.Name())
+	v := newVolume("test", "/mnt/test", "bucket")
+	c := *old
+	v.c = &c
+	d.volumes["test"] = v
+
+	cfg, err := d.Reload()
+	if err != nil {
+		t.Fatalf("An error occured while reloading the config: %s", err)
+	}
+	if cfg.Server != "new:9000" || cfg.SecretKey != redacted {
+		t.Errorf("Expected the new redacted config, got %#v", cfg)
+	}
+	if !d.c.Matches("new:9000", "newKey", "newSecret", false, client.Options{}) {
+		t.Errorf("Expected a client for the new config, got %#v", d.c)
+	}
+	if !v.c.Matches("old:9000", "oldKey", "oldSecret", false, client.Options{}) {
+		t.Errorf("Expected the volume to keep its client, got %#v", v.c)
+	}
+}
+
+func TestReloadInvalidConfig(t *testing.T) {
+	fh, err := ioutil.TempFile("", "miniovol-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fh.Name())
+	fh.WriteString(`{"server":`)
+	fh.Close()
+
+	d := NewMinioDriver(nil, false)
+	d.SetConfig(Config{Server: "old:9000"}, fh.Name())
+	if _, err := d.Reload(); err == nil {
+		t.Error("Expected an invalid config to fail the reload")
+	}
+	if d.cfg.Server != "old:9000" {
+		t.Errorf("Expected the config to be kept, got %#v", d.cfg)
+	}
+}
+
+func TestConfigChanges(t *testing.T) {
+	old := Config{Server: "a:9000", AccessKey: "key", SecretKey: "secret"}
+	cfg := Config{Server: "a:9000", AccessKey: "key", SecretKey: "rotated", Secure: true}
+	if changed := configChanges(old, cfg); !reflect.DeepEqual(changed, []string{"secretKey", "secure"}) {
+		t.Errorf("Expected secretKey and secure to change, got %v", changed)
+	}
+	cfg = Config{Server: "a:9000", AccessKey: "key", SecretKey: "secret", Profiles: map[string]Profile{"b": {Server: "b:9000"}}}
+	if changed := configChanges(old, cfg); !reflect.DeepEqual(changed, []string{"profiles"}) {
+		t.Errorf("Expected profiles to change, got %v", changed)
+	}
+}
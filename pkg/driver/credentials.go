@@ -0,0 +1,157 @@
+package driver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+// SecretKeyFileInterval is how often the secretKeyFile of the volumes is
+// checked for a new key.
+const SecretKeyFileInterval = 30 * time.Second
+
+// withSecretKeyFile returns opts with secretKey set to the content of the
+// secretKeyFile option, if one is given. The file takes precedence over
+// secretKey, which may come from the driver configuration.
+func withSecretKeyFile(opts map[string]string) (map[string]string, error) {
+	path, err := checkParam("secretKeyFile", opts)
+	if err != nil {
+		return opts, nil
+	}
+	secretKey, err := readSecretKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return withOption(opts, "secretKey", secretKey), nil
+}
+
+// readSecretKeyFile reads a secret key from a file, ignoring the surrounding
+// whitespace.
+func readSecretKeyFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret key: %s", err)
+	}
+	secretKey := strings.TrimSpace(string(data))
+	if secretKey == "" {
+		return "", fmt.Errorf("secret key file %s is empty", path)
+	}
+	return secretKey, nil
+}
+
+// RotateCredentials replaces the credentials of a volume. An empty access key
+// keeps the current one. The new credentials are checked against the server
+// before they are used, and a mounted volume is remounted with them. A volume
+// with active mounts keeps its current mount, and the new credentials apply
+// once the last of them is unmounted.
+func (d *MinioDriver) RotateCredentials(name, accessKey, secretKey string) error {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	v, exists := d.volumes[name]
+	if !exists {
+		return newErrVolNotFound(name)
+	}
+	return d.rotateCredentials(name, v, accessKey, secretKey)
+}
+
+// rotateCredentials replaces the credentials of a volume. The caller must hold
+// the driver lock.
+func (d *MinioDriver) rotateCredentials(name string, v *minioVolume, accessKey, secretKey string) error {
+	if accessKey == "" {
+		accessKey = v.c.AccesKeyID
+	}
+	if secretKey == "" {
+		return fmt.Errorf("a secret key is required")
+	}
+	check := checkEndpoint(endpoint{
+		server:    v.c.ServerURI,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		secure:    v.c.Secure,
+		opts:      v.c.Options,
+	})
+	if !check.Healthy {
+		return fmt.Errorf("new credentials of volume %s were refused by %s: %s", name, check.Name, check.Error)
+	}
+
+	c, err := client.NewMinioClientWithOptions(v.c.ServerURI, accessKey, secretKey, v.bucketName, v.c.Secure, v.c.Options)
+	if err != nil {
+		return err
+	}
+	c.Encryption = v.c.Encryption
+	v.c = c
+	v.credentialsUpdated = time.Now().UTC()
+	restartReplication(v)
+	glog.V(0).Infof("Rotated credentials of volume %s", name)
+	if err := d.saveState(); err != nil {
+		glog.Warningf("Failed to save state after rotating credentials of volume %s: %s", name, err)
+	}
+
+	if len(v.mounts) > 0 {
+		v.remountPending = true
+		glog.V(0).Infof("Volume %s has %d active mounts, its new credentials apply once they are unmounted", name, len(v.mounts))
+		return nil
+	}
+	mounted, err := readMounts()
+	if err != nil {
+		return err
+	}
+	if !mounted[v.mountpoint] {
+		return nil
+	}
+	if err := d.unmountVolume(v); err != nil {
+		return fmt.Errorf("failed to unmount volume %s to use its new credentials: %s", name, err)
+	}
+	if err := d.mountVolume(v); err != nil {
+		return fmt.Errorf("failed to remount volume %s with its new credentials: %s", name, err)
+	}
+	glog.V(0).Infof("Remounted volume %s with its new credentials", name)
+	return nil
+}
+
+// WatchSecretKeyFiles checks the secretKeyFile of the volumes every interval
+// and rotates the credentials of the volumes whose key changed, until stopCh
+// is closed.
+func (d *MinioDriver) WatchSecretKeyFiles(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			d.checkSecretKeyFiles()
+		}
+	}
+}
+
+// checkSecretKeyFiles rotates the credentials of the volumes whose
+// secretKeyFile holds a new key.
+func (d *MinioDriver) checkSecretKeyFiles() {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	for name, v := range d.volumes {
+		if v.secretKeyFile == "" {
+			continue
+		}
+		secretKey, err := readSecretKeyFile(v.secretKeyFile)
+		if err != nil {
+			glog.Warningf("Failed to check secret key of volume %s: %s", name, err)
+			continue
+		}
+		if secretKey == v.c.SecretAccessKey {
+			continue
+		}
+		glog.V(0).Infof("Secret key file %s of volume %s changed", v.secretKeyFile, name)
+		if err := d.rotateCredentials(name, v, "", secretKey); err != nil {
+			glog.Warningf("Failed to rotate credentials of volume %s: %s", name, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package driver
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+func writeSecretKeyFile(t *testing.T, secretKey string) string {
+	fh, err := ioutil.TempFile("", "miniovol-secret-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh.WriteString(secretKey)
+	fh.Close()
+	return fh.Name()
+}
+
+func TestWithSecretKeyFile(t *testing.T) {
+	path := writeSecretKeyFile(t, "fileSecret\n")
+	defer os.Remove(path)
+
+	opts, err := withSecretKeyFile(map[string]string{"secretKey": "default", "secretKeyFile": path})
+	if err != nil {
+		t.Fatalf("An error occured while reading the secret key file: %s", err)
+	}
+	if opts["secretKey"] != "fileSecret" {
+		t.Errorf("Expected the secret key of the file, got %s", opts["secretKey"])
+	}
+
+	opts, err = withSecretKeyFile(map[string]string{"secretKey": "default"})
+	if err != nil || opts["secretKey"] != "default" {
+		t.Errorf("Expected options without a file to be kept, got %#v and %v", opts, err)
+	}
+
+	empty := writeSecretKeyFile(t, " \n")
+	defer os.Remove(empty)
+	if _, err := withSecretKeyFile(map[string]string{"secretKeyFile": empty}); err == nil {
+		t.Error("Expected an empty secret key file to fail")
+	}
+}
+
+func newCredentialsDriver(t *testing.T, server string) (*MinioDriver, *minioVolume) {
+	c, err := client.NewMinioClient(server, "access", "old", "testbucket", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewMinioDriver(nil, false)
+	v := newVolume("miniovol-1", "/mnt/miniovol-test-unmounted", "testbucket")
+	v.c = c
+	d.volumes["test"] = v
+	return d, v
+}
+
+func TestRotateCredentials(t *testing.T) {
+	ts := newTestServer("rotated")
+	defer ts.Close()
+	d, v := newCredentialsDriver(t, strings.TrimPrefix(ts.URL, "http://"))
+
+	if err := d.RotateCredentials("test", "", "new"); err == nil {
+		t.Error("Expected credentials refused by the server to fail the rotation")
+	}
+	if v.c.SecretAccessKey != "old" {
+		t.Errorf("Expected refused credentials not to be used, got %#v", v.c)
+	}
+
+	if err := d.RotateCredentials("test", "rotated", "new"); err != nil {
+		t.Fatalf("An error occured while rotating the credentials: %s", err)
+	}
+	if v.c.AccesKeyID != "rotated" || v.c.SecretAccessKey != "new" || v.credentialsUpdated.IsZero() {
+		t.Errorf("Expected the new credentials to be used, got %#v", v)
+	}
+
+	if err := d.RotateCredentials("missing", "", "new"); err == nil {
+		t.Error("Expected rotating the credentials of a missing volume to fail")
+	}
+}
+
+func TestCheckSecretKeyFiles(t *testing.T) {
+	ts := newTestServer("access")
+	defer ts.Close()
+	d, v := newCredentialsDriver(t, strings.TrimPrefix(ts.URL, "http://"))
+	v.secretKeyFile = writeSecretKeyFile(t, "old")
+	defer os.Remove(v.secretKeyFile)
+
+	d.checkSecretKeyFiles()
+	if !v.credentialsUpdated.IsZero() {
+		t.Errorf("Expected an unchanged key not to rotate the credentials, got %#v", v)
+	}
+
+	ioutil.WriteFile(v.secretKeyFile, []byte("new\n"), 0600)
+	d.checkSecretKeyFiles()
+	if v.c.SecretAccessKey != "new" || v.credentialsUpdated.IsZero() {
+		t.Errorf("Expected the key of the file to be used, got %#v", v.c)
+	}
+}
+
+func TestRotateCredentialsActiveMounts(t *testing.T) {
+	ts := newTestServer("access")
+	defer ts.Close()
+	d, v := newCredentialsDriver(t, strings.TrimPrefix(ts.URL, "http://"))
+	v.mounts["a"] = struct{}{}
+
+	if err := d.RotateCredentials("test", "", "new"); err != nil {
+		t.Fatalf("An error occured while rotating the credentials: %s", err)
+	}
+	if v.c.SecretAccessKey != "new" || !v.remountPending {
+		t.Errorf("Expected the remount to wait for the active mounts, got %#v", v)
+	}
+	if status := d.volumeStatus(v); status["remountPending"] != true {
+		t.Errorf("Expected the pending remount in the status, got %#v", status)
+	}
+}
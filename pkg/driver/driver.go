@@ -5,7 +5,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/docker/go-plugins-helpers/volume"
 	"github.com/golang/glog"
@@ -16,13 +18,67 @@ import (
 var capability volume.Capability
 
 type minioVolume struct {
-	name        string
-	mountpoint  string
-	connections int
+	name       string
+	mountpoint string
+
+	// mounts holds the IDs of the mount requests currently using the volume.
+	mounts map[string]struct{}
 
 	// NOTE: check to see if buckets would really collide if we specify them only
 	// in the driver, instead of attaching them individually to each volume.
 	bucketName string
+
+	// sizeLimit is the size quota set on the volume, zero means unlimited.
+	sizeLimit int64
+
+	// expireDays and expirePrefix describe the lifecycle rule installed on
+	// the bucket of the volume, zero days means no rule.
+	expireDays   int
+	expirePrefix string
+
+	// versioning and objectLock hold the configuration the plugin applied
+	// to the bucket when it created it, they are empty for existing buckets.
+	versioning string
+	objectLock string
+
+	// sse is the server side encryption mode of the volume's objects, and
+	// sseCustomerKeyFile the file their SSE-C key is read from.
+	sse                string
+	sseCustomerKeyFile string
+
+	// c is the client the volume was created with. Volumes keep using it
+	// after the configuration of the driver is reloaded.
+	c *client.MinioClient
+
+	// endpoint is the endpoint of c the volume is mounted from, it matters
+	// for the clients with several endpoints only.
+	endpoint string
+
+	// profile is the name of the profile the volume was created with, if
+	// any.
+	profile string
+
+	// replicateTo is the profile the bucket of the volume is mirrored to, if
+	// any, by replicator. replicaCreated is set once the plugin created the
+	// bucket the volume is mirrored to.
+	replicateTo    string
+	replicaCreated bool
+	replicator     *replicator
+
+	// secretKeyFile is the file the secret key of the volume is read from,
+	// if any, and credentialsUpdated is when the credentials were set.
+	secretKeyFile      string
+	credentialsUpdated time.Time
+
+	// remountPending is set when the client of the volume changed while it
+	// had active mounts, which keep using the previous client until the last
+	// of them is unmounted.
+	remountPending bool
+
+	snapshots []Snapshot
+
+	// status caches what the server reports about the bucket.
+	status *bucketStatus
 }
 
 // MinioDriver is the driver used by docker.
@@ -35,6 +91,23 @@ type MinioDriver struct {
 	secretKey string
 	secure    bool
 	volumes   map[string]*minioVolume
+
+	// clones holds the buckets of volumes whose clone failed midway, so that
+	// a new Create request for the same volume can resume the copy.
+	clones map[string]string
+	// creating holds the volumes whose bucket Create is populating.
+	creating map[string]struct{}
+
+	cfg     Config
+	cfgPath string
+	metrics *metrics
+
+	// statePath is the file the registry is persisted to, if any, and
+	// broken holds the volumes of the state that couldn't be restored, which
+	// are persisted again as they were.
+	statePath string
+	broken    map[string]volumeState
+	inflight  *inflight
 }
 
 // NewMinioDriver creates a new driver for the docker plugin.
@@ -43,8 +116,14 @@ func NewMinioDriver(client *client.MinioClient, secure bool) *MinioDriver {
 		c: client,
 		m: &sync.RWMutex{},
 
-		secure:  secure,
-		volumes: make(map[string]*minioVolume),
+		secure:   secure,
+		volumes:  make(map[string]*minioVolume),
+		clones:   make(map[string]string),
+		creating: make(map[string]struct{}),
+		metrics:  newMetrics(),
+		broken:   make(map[string]volumeState),
+
+		inflight: &inflight{},
 	}
 }
 
@@ -53,40 +132,193 @@ func newVolume(name, mountPoint, bucket string) *minioVolume {
 		name:       name,
 		mountpoint: mountPoint,
 		bucketName: bucket,
+		mounts:     make(map[string]struct{}),
+		status:     &bucketStatus{},
 	}
 }
 
-// Create creates a new volume with the appropiate data.
+// Create creates a new volume with the appropiate data. Probing the backend,
+// cloning and seeding run without the driver lock, and the volume is only
+// registered once they succeed.
 func (d *MinioDriver) Create(r volume.Request) volume.Response {
+	glog.V(1).Infof("Create request is: %#v", r)
+	d.m.Lock()
+	p, err := d.prepareVolume(r.Name, r.Options)
+	d.m.Unlock()
+	if err != nil {
+		return volumeResp("", "", nil, capability, err.Error())
+	}
+
+	if err = checkBackend(p.options); err == nil {
+		d.m.Lock()
+		err = d.createVolumeClient(p)
+		d.m.Unlock()
+	}
+	if err == nil {
+		err = d.populateVolume(r.Name, p)
+	}
+
 	d.m.Lock()
 	defer d.m.Unlock()
+	delete(d.creating, r.Name)
+	if err != nil {
+		return volumeResp("", "", nil, capability, err.Error())
+	}
+	if err := d.registerVolume(r.Name, p); err != nil {
+		return volumeResp("", "", nil, capability, err.Error())
+	}
+	glog.V(1).Infof("this is the d.volumes: %#v", d.volumes)
+	return volumeResp("", "", nil, capability, "")
+}
 
-	glog.V(1).Infof("Create request is: %#v", r)
-	if err := d.createClient(r.Options); err != nil {
-		return volumeResp("",
-			"",
-			nil,
-			capability,
-			fmt.Errorf("error creating client: %s", err).Error(),
-		)
+// pendingVolume is a volume whose bucket is being populated by Create.
+type pendingVolume struct {
+	c       *client.MinioClient
+	options map[string]string
+
+	// srcBucket and srcPrefix are what the volume is cloned from, if
+	// anything.
+	srcBucket string
+	srcPrefix string
+
+	// replica is the bucket the volume is mirrored to, if it's replicated.
+	replica *client.MinioClient
+
+	sizeLimit    int64
+	expireDays   int
+	expirePrefix string
+	versioning   string
+	objectLock   string
+}
+
+// prepareVolume checks the options of a new volume, which is marked as being
+// created until Create is done with it. The caller must hold the driver lock.
+func (d *MinioDriver) prepareVolume(name string, opts map[string]string) (*pendingVolume, error) {
+	if _, ok := d.creating[name]; ok {
+		return nil, fmt.Errorf("volume %s is already being created", name)
+	}
+	options, err := d.cfg.withDefaults(opts)
+	if err != nil {
+		return nil, err
+	}
+	if options, err = withSecretKeyFile(options); err != nil {
+		return nil, err
+	}
+	if err := checkBackendName(options["backend"]); err != nil {
+		return nil, err
+	}
+	if err := checkUnsupported(options); err != nil {
+		return nil, err
+	}
+	p := &pendingVolume{options: options}
+	if p.sizeLimit, err = parseQuota(options); err != nil {
+		return nil, err
+	}
+	if p.expireDays, p.expirePrefix, err = parseExpiration(options); err != nil {
+		return nil, err
+	}
+	if p.versioning, p.objectLock, _, err = parseVersioning(options); err != nil {
+		return nil, err
+	}
+	if options["bucket"] != "" {
+		// Existing buckets are used as they are, see bucketConfigParams.
+		p.expireDays, p.expirePrefix = 0, ""
+		p.versioning, p.objectLock = "", ""
+	}
+	if options["seedFrom"] != "" && options["sse"] == client.SSEC {
+		return nil, fmt.Errorf("seedFrom option can't be used with sse=c, large files would be uploaded without the customer key")
+	}
+	if profile := options["replicateTo"]; profile != "" {
+		if _, ok := d.cfg.Profiles[profile]; !ok {
+			return nil, fmt.Errorf("unknown replicateTo profile %s", profile)
+		}
+		if options["sse"] == client.SSEC {
+			return nil, fmt.Errorf("replicateTo option can't be used with sse=c, the objects can't be read without the customer key")
+		}
+	}
+	if p.srcBucket, p.srcPrefix, err = d.cloneSource(options); err != nil {
+		return nil, err
+	}
+	if bucket, ok := d.clones[name]; ok {
+		glog.V(0).Infof("Resuming clone of volume %s into bucket %s", name, bucket)
+		p.options = withOption(options, "bucket", bucket)
+	}
+	d.creating[name] = struct{}{}
+	return p, nil
+}
+
+// createVolumeClient creates or selects the bucket of a new volume and gives
+// it its own client. The caller must hold the driver lock.
+func (d *MinioDriver) createVolumeClient(p *pendingVolume) error {
+	if err := d.createClient(p.options); err != nil {
+		return fmt.Errorf("error creating client: %s", err)
+	}
+	c := *d.c
+	p.c = &c
+	return nil
+}
+
+// populateVolume clones and seeds the bucket of a new volume, sets its quota
+// and prepares the bucket it's replicated to. The driver lock must not be
+// held.
+func (d *MinioDriver) populateVolume(name string, p *pendingVolume) error {
+	if err := d.cloneVolume(name, p); err != nil {
+		return fmt.Errorf("error cloning volume: %s", err)
+	}
+	if err := seedVolume(name, p); err != nil {
+		return fmt.Errorf("error seeding volume: %s", err)
+	}
+	if err := setQuota(p.c, p.sizeLimit); err != nil {
+		return fmt.Errorf("error setting volume quota: %s", err)
 	}
+	if profile := p.options["replicateTo"]; profile != "" {
+		var err error
+		if p.replica, err = d.replicationTarget(profile, p.c, false); err != nil {
+			return fmt.Errorf("error replicating volume: %s", err)
+		}
+	}
+	return nil
+}
 
+// registerVolume creates the mountpoint of a new volume, starts its
+// replication and adds it to the volumes of the driver. Only creating the
+// mountpoint can fail, before anything else is done. The caller must hold
+// the driver lock.
+func (d *MinioDriver) registerVolume(name string, p *pendingVolume) error {
 	volPath := createName(volumePrefix)
 	volMount := filepath.Join("/mnt", volPath)
 	if err := d.createVolumeMount(volMount); err != nil {
-		return volumeResp("", "", nil, capability, err.Error())
+		return err
 	}
 
 	volName := createName(volumePrefix)
-	d.volumes[r.Name] = newVolume(volName, volMount, d.c.BucketName)
-	glog.V(1).Infof("this is the d.volumes: %#v", d.volumes)
-	return volumeResp("", "", nil, capability, "")
+	v := newVolume(volName, volMount, p.c.BucketName)
+	v.sizeLimit = p.sizeLimit
+	v.expireDays = p.expireDays
+	v.expirePrefix = p.expirePrefix
+	v.versioning = p.versioning
+	v.objectLock = p.objectLock
+	if p.c.Encryption != nil {
+		v.sse = p.c.Encryption.Mode
+		if v.sse == client.SSEC {
+			v.sseCustomerKeyFile = p.options["sseCustomerKeyFile"]
+		}
+	}
+	v.c = p.c
+	v.profile = p.options["profile"]
+	v.secretKeyFile = p.options["secretKeyFile"]
+	v.credentialsUpdated = time.Now().UTC()
+	if v.replicateTo = p.options["replicateTo"]; v.replicateTo != "" {
+		startReplication(name, v, p.replica)
+	}
+	d.volumes[name] = v
+	return nil
 }
 
 // List lists all currently available volumes.
 func (d *MinioDriver) List(r volume.Request) volume.Response {
-	d.m.Lock()
-	defer d.m.Unlock()
+	d.m.RLock()
+	defer d.m.RUnlock()
 
 	var vols []*volume.Volume
 	for name, v := range d.volumes {
@@ -101,30 +333,79 @@ func (d *MinioDriver) List(r volume.Request) volume.Response {
 
 // Get retrieves information about a current volume.
 func (d *MinioDriver) Get(r volume.Request) volume.Response {
-	d.m.Lock()
-	defer d.m.Unlock()
+	d.m.RLock()
+	defer d.m.RUnlock()
 
 	v, exists := d.volumes[r.Name]
 	if !exists {
 		return volumeResp("", "", nil, capability, newErrVolNotFound(r.Name).Error())
 	}
 
-	return volumeResp(v.mountpoint, r.Name, nil, capability, "")
+	resp := volumeResp(v.mountpoint, r.Name, nil, capability, "")
+	resp.Volume.Status = d.volumeStatus(v)
+	return resp
 }
 
-// Remove attempts to remove a volume if it's not currently in use.
+// volumeStatus returns the status reported for a volume by Get. It doesn't
+// wait for the server, the usage and configuration of the bucket are the
+// ones last cached. The caller must hold the driver lock, for reading at
+// least.
+func (d *MinioDriver) volumeStatus(v *minioVolume) map[string]interface{} {
+	status := make(map[string]interface{})
+	addBucketStatus(v, status)
+	if v.sizeLimit > 0 {
+		status["sizeLimit"] = v.sizeLimit
+	}
+	if v.expireDays > 0 {
+		status["expireDays"] = v.expireDays
+		status["expirePrefix"] = v.expirePrefix
+	}
+	if v.sse != "" {
+		status["encryption"] = v.sse
+	}
+	if v.profile != "" {
+		status["profile"] = v.profile
+	}
+	if v.replicator != nil {
+		status["replication"] = v.replicator.status()
+	}
+	if len(v.c.Endpoints()) > 1 {
+		endpoint := v.endpoint
+		if endpoint == "" {
+			endpoint = v.c.Endpoint()
+		}
+		status["endpoint"] = endpoint
+	}
+	if !v.credentialsUpdated.IsZero() {
+		status["credentialAge"] = time.Since(v.credentialsUpdated).String()
+	}
+	if v.remountPending {
+		status["remountPending"] = true
+	}
+	if len(status) == 0 {
+		return nil
+	}
+	return status
+}
+
+// Remove attempts to remove a volume if it's not currently in use. The bucket
+// of the volume is always kept, which object locked volumes rely on.
 func (d *MinioDriver) Remove(r volume.Request) volume.Response {
 	d.m.Lock()
-	defer d.m.Lock()
+	defer d.m.Unlock()
 
 	v, exists := d.volumes[r.Name]
 	if !exists {
 		return volumeResp("", "", nil, capability, newErrVolNotFound(r.Name).Error())
 	}
-	if v.connections == 0 {
+	if len(v.mounts) == 0 {
 		if err := os.RemoveAll(v.mountpoint); err != nil {
 			return volumeResp("", "", nil, capability, err.Error())
 		}
+		if v.objectLock != "" {
+			glog.V(0).Infof("Keeping object locked bucket %s of volume %s", v.bucketName, r.Name)
+		}
+		stopReplication(v)
 		delete(d.volumes, r.Name)
 		return volumeResp("", "", nil, capability, "")
 	}
@@ -162,8 +443,8 @@ func (d *MinioDriver) Mount(r volume.MountRequest) volume.Response {
 		return volumeResp("", "", nil, capability, newErrVolNotFound(r.Name).Error())
 	}
 
-	if v.connections > 0 {
-		v.connections++
+	if len(v.mounts) > 0 {
+		v.mounts[r.ID] = struct{}{}
 		return volumeResp(v.mountpoint, r.Name, nil, capability, "")
 	}
 
@@ -172,9 +453,9 @@ func (d *MinioDriver) Mount(r volume.MountRequest) volume.Response {
 		return volumeResp("", "", nil, capability, err.Error())
 	}
 
-	// if the mount was successful, then increment the number of connections we
-	// have to the mount.
-	v.connections++
+	// if the mount was successful, then keep track of the request that uses
+	// the mount.
+	v.mounts[r.ID] = struct{}{}
 	return volumeResp(v.mountpoint, r.Name, nil, capability, "")
 }
 
@@ -190,18 +471,56 @@ func (d *MinioDriver) Unmount(r volume.UnmountRequest) volume.Response {
 		return volumeResp("", "", nil, capability, newErrVolNotFound(r.Name).Error())
 	}
 
-	if v.connections <= 1 {
-		if err := d.unmountVolume(v); err != nil {
-			glog.Warningf("Unmounting %s volume failed with: %s", v, err)
-			return volumeResp("", "", nil, capability, err.Error())
-		}
-		v.connections = 0
+	delete(v.mounts, r.ID)
+	if len(v.mounts) > 0 {
 		return volumeResp("", "", nil, capability, "")
 	}
-	v.connections--
+	if err := d.unmountVolume(v); err != nil {
+		glog.Warningf("Unmounting %s volume failed with: %s", v.name, err)
+		v.mounts[r.ID] = struct{}{}
+		return volumeResp("", "", nil, capability, err.Error())
+	}
+	go abortStaleUploads(r.Name, v.c, StaleUploadAge)
 	return volumeResp("", "", nil, capability, "")
 }
 
+// SetExpiration replaces the lifecycle rule of a volume's bucket so that the
+// objects under prefix expire after the given number of days. Zero days
+// removes the rule. Only the rule of the plugin is changed, the other rules of
+// the bucket are kept. The driver isn't locked while the rule is replaced.
+func (d *MinioDriver) SetExpiration(name string, days int, prefix string) error {
+	if days < 0 {
+		return fmt.Errorf("invalid expiration of %d days", days)
+	}
+	c, err := d.volumeClient(name)
+	if err != nil {
+		return err
+	}
+	if days == 0 {
+		prefix = ""
+		err = c.RemoveBucketExpiration(c.BucketName)
+	} else {
+		err = c.SetBucketExpiration(c.BucketName, days, prefix)
+	}
+	if err != nil {
+		return err
+	}
+
+	d.m.Lock()
+	defer d.m.Unlock()
+	v, exists := d.volumes[name]
+	if !exists {
+		return newErrVolNotFound(name)
+	}
+	v.expireDays = days
+	v.expirePrefix = prefix
+	glog.V(0).Infof("Set expiration of volume %s to %d days under %q", name, days, prefix)
+	if err := d.saveState(); err != nil {
+		glog.Warningf("Failed to save state after setting expiration of volume %s: %s", name, err)
+	}
+	return nil
+}
+
 // Capabilities returns the capabilities needed for this plugin.
 func (d *MinioDriver) Capabilities(r volume.Request) volume.Response {
 	localCapability := volume.Capability{
@@ -215,11 +534,9 @@ func (d *MinioDriver) Capabilities(r volume.Request) volume.Response {
 // filesystem with the minfs driver.
 func (d *MinioDriver) mountVolume(volume *minioVolume) error {
 
-	minioPath := fmt.Sprintf("%s/%s", d.server, volume.bucketName)
-
-	//NOTE: make this adjustable in the future for https if secure is passed.
-	cmd := fmt.Sprintf("mount -t minfs http://%s %s", minioPath, volume.mountpoint)
-	if err := provisionConfig(d); err != nil {
+	endpoint := volume.c.Endpoint()
+	cmd := fmt.Sprintf("mount -t minfs %s %s", volume.c.BucketURL(volume.bucketName), volume.mountpoint)
+	if err := provisionConfig(volume.c.AccesKeyID, volume.c.SecretAccessKey); err != nil {
 		return err
 	}
 
@@ -229,6 +546,8 @@ func (d *MinioDriver) mountVolume(volume *minioVolume) error {
 		glog.V(1).Infof("Dump output of command: %#v", out)
 		return err
 	}
+	volume.endpoint = endpoint
+	volume.remountPending = false
 	return nil
 }
 
@@ -241,66 +560,222 @@ func (d *MinioDriver) unmountVolume(volume *minioVolume) error {
 // createClient is a helper function that uses minio go bindings to instantiate
 // a new session with minio's API.
 func (d *MinioDriver) createClient(options map[string]string) error {
-	var secure bool
-
-	server, err := checkParam("server", options)
+	e, err := endpointFromOptions(options)
 	if err != nil {
-		glog.Warning("missing server option")
 		return err
 	}
-	d.server = server
+	d.server = e.server
+	d.accessKey = e.accessKey
+	d.secretKey = e.secretKey
 
-	accessKey, err := checkParam("accessKey", options)
-	if err != nil {
-		glog.Warning("missing accessKey option")
+	if !d.c.Matches(e.server, e.accessKey, e.secretKey, e.secure, e.opts) {
+		d.c, err = client.NewMinioClientWithOptions(e.server, e.accessKey, e.secretKey, "", e.secure, e.opts)
+		if err != nil {
+			glog.Warningf("Failed to create new client: %s", err)
+			glog.V(1).Infof("server: %s - accesKey: %s - secretKey: %s - secure: %t", e.server, e.accessKey, e.secretKey, e.secure)
+			return err
+		}
+	}
+	if d.c.Encryption, err = parseEncryption(options); err != nil {
 		return err
 	}
-	d.accessKey = accessKey
-	secretKey, err := checkParam("secretKey", options)
-	if err != nil {
+
+	bucketName, err := checkParam("bucket", options)
+	if err != nil || bucketName == "" {
+		if err = d.createBucket(options); err != nil {
+			return err
+		}
+		return nil
+	}
+	for _, param := range bucketConfigParams {
+		if _, err := checkParam(param, options); err == nil {
+			glog.Warningf("Ignoring %s option for existing bucket %s", param, bucketName)
+		}
+	}
+	d.c.BucketName = bucketName
+	return nil
+}
+
+// endpointFromOptions returns the server, credentials and provider options
+// passed with the volume options.
+func endpointFromOptions(options map[string]string) (endpoint, error) {
+	e := endpoint{}
+	var err error
+	if e.server, err = checkParam("server", options); err != nil {
+		glog.Warning("missing server option")
+		return e, err
+	}
+	if e.accessKey, err = checkParam("accessKey", options); err != nil {
+		glog.Warning("missing accessKey option")
+		return e, err
+	}
+	if e.secretKey, err = checkParam("secretKey", options); err != nil {
 		glog.Warning("missing secretKey option")
-		return err
+		return e, err
 	}
-	d.secretKey = secretKey
 	// TODO: remember to fix this, since the user could pass false and it would
 	// become true.
-	_, err = checkParam("secure", options)
-	if err == nil {
+	if _, err = checkParam("secure", options); err == nil {
 		glog.Warning("setting secure key true")
-		secure = true
+		e.secure = true
 	}
+	e.opts, err = parseClientOptions(options)
+	return e, err
+}
 
-	if d.c == nil {
-		d.c, err = client.NewMinioClient(server, accessKey, secretKey, "", secure)
-		if err != nil {
-			glog.Warningf("Failed to create new client", err)
-			glog.V(1).Infof("server: %s - accesKey: %s - secretKey: %s - secure: %s", server, accessKey, secretKey, secure)
-			return err
+// cloneVolume populates the bucket of a new volume with the contents of the
+// volume passed with cloneFrom or the bucket passed with cloneFromBucket. If
+// the copy fails, the bucket is remembered so that a later Create request for
+// the same volume resumes the copy instead of starting over. The driver lock
+// must not be held.
+func (d *MinioDriver) cloneVolume(name string, p *pendingVolume) error {
+	if p.srcBucket == "" {
+		return nil
+	}
+
+	workers := 0
+	if w, err := checkParam("cloneWorkers", p.options); err == nil {
+		if workers, err = strconv.Atoi(w); err != nil {
+			return fmt.Errorf("invalid cloneWorkers option %s: %s", w, err)
 		}
 	}
-	// TODO: implement reusability of a bucket by passing its name as a parameter.
-	bucketName, err := checkParam("bucket", options)
-	if err != nil || bucketName == "" {
-		if err = d.createBucket(); err != nil {
-			return err
+
+	glog.V(0).Infof("Cloning %s/%s into bucket %s", p.srcBucket, p.srcPrefix, p.c.BucketName)
+	err := p.c.CopyPrefix(p.srcBucket, p.srcPrefix, workers, func(cp client.CopyProgress) {
+		if done := cp.Copied + cp.Skipped; done%cloneProgressInterval == 0 {
+			glog.V(0).Infof("Cloning volume %s: %d objects done (%d skipped), %d bytes copied",
+				name, done, cp.Skipped, cp.Bytes)
 		}
+	})
+
+	d.m.Lock()
+	defer d.m.Unlock()
+	if err != nil {
+		glog.Warningf("Cloning volume %s failed, it can be resumed: %s", name, err)
+		d.clones[name] = p.c.BucketName
+		return err
 	}
+	delete(d.clones, name)
+	glog.V(0).Infof("Finished cloning volume %s", name)
 	return nil
 }
 
+// seedVolume populates the bucket of a new volume with the contents of the
+// archive or directory passed with seedFrom.
+func seedVolume(name string, p *pendingVolume) error {
+	source, err := checkParam("seedFrom", p.options)
+	if err != nil {
+		return nil
+	}
+
+	glog.V(0).Infof("Seeding volume %s from %s", name, source)
+	count, err := p.c.Seed(source)
+	if err != nil {
+		glog.Warningf("Seeding volume %s failed after %d objects: %s", name, count, err)
+		return err
+	}
+	glog.V(0).Infof("Seeded volume %s with %d objects", name, count)
+	return nil
+}
+
+// setQuota sets the size quota on the bucket of c. Servers that don't
+// implement the MinIO admin API only get a warning, since the quota is still
+// reported by Get.
+func setQuota(c *client.MinioClient, sizeLimit int64) error {
+	if sizeLimit == 0 {
+		return nil
+	}
+	err := c.SetBucketQuota(c.BucketName, sizeLimit)
+	if reqErr, ok := err.(client.RequestError); ok && reqErr.NotImplemented() {
+		glog.Warningf("Server doesn't support bucket quotas, %s will not be enforced: %s", c.BucketName, err)
+		return nil
+	}
+	return err
+}
+
+// cloneSource returns the bucket and prefix that the new volume should be
+// cloned from. An empty bucket means that no clone was requested. The copies
+// are server side and made with the credentials of the new volume, so a
+// volume is only cloned from another volume of the same server and
+// credentials, and never from an SSE-C volume.
+func (d *MinioDriver) cloneSource(options map[string]string) (string, string, error) {
+	volName, volErr := checkParam("cloneFrom", options)
+	bucketPath, bucketErr := checkParam("cloneFromBucket", options)
+	switch {
+	case volErr == nil && bucketErr == nil:
+		return "", "", fmt.Errorf("cloneFrom and cloneFromBucket are mutually exclusive")
+	case volErr == nil:
+		v, exists := d.volumes[volName]
+		if !exists {
+			return "", "", newErrVolNotFound(volName)
+		}
+		if v.sse == client.SSEC {
+			return "", "", fmt.Errorf("volume %s uses sse=c, its objects can't be copied without the customer key", volName)
+		}
+		if v.c.ServerURI != options["server"] || v.c.AccesKeyID != options["accessKey"] {
+			return "", "", fmt.Errorf("volume %s is on another server or uses other credentials, cloneFrom only copies within a server", volName)
+		}
+		return v.bucketName, "", nil
+	case bucketErr == nil:
+		bucket, prefix := client.SplitBucketPrefix(bucketPath)
+		return bucket, prefix, nil
+	}
+	return "", "", nil
+}
+
 // createBucket is a helper function that creates a bucket on minio to be used
-// by the volume plugin to mount a minio bucket locally.
-func (d *MinioDriver) createBucket() error {
+// by the volume plugin to mount a minio bucket locally. The bucket is then
+// configured according to the volume options.
+func (d *MinioDriver) createBucket(options map[string]string) error {
+	versioning, objectLock, retentionDays, err := parseVersioning(options)
+	if err != nil {
+		return err
+	}
+
 	bucket := createName(bucketPrefix)
 	exists, err := d.c.Client.BucketExists(bucket)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		// TODO: in the future, let the user set "location" so that this works with
-		// aws s3.
-		if err := d.c.Client.MakeBucket(bucket, ""); err != nil {
-			glog.Warningf("Failed to create bucket %s at ", bucket)
+		region := bucketRegion(d.c)
+		if objectLock != "" {
+			err = d.c.MakeLockedBucket(bucket, region)
+		} else {
+			err = d.c.Client.MakeBucket(bucket, region)
+		}
+		if err != nil {
+			glog.Warningf("Failed to create bucket %s in %s: %s", bucket, region, err)
+			return err
+		}
+	}
+
+	if objectLock != "" {
+		if err := d.c.SetBucketRetention(bucket, objectLock, retentionDays); err != nil {
+			glog.Warningf("Failed to set object lock on bucket %s: %s", bucket, err)
+			return err
+		}
+	}
+	if versioning != "" && objectLock == "" {
+		if err := d.c.SetBucketVersioning(bucket, versioning); err != nil {
+			glog.Warningf("Failed to set versioning on bucket %s: %s", bucket, err)
+			return err
+		}
+	}
+	if enc := d.c.Encryption; enc != nil && enc.Mode != client.SSEC {
+		if err := d.c.SetBucketEncryption(bucket, enc); err != nil {
+			glog.Warningf("Failed to set default encryption on bucket %s: %s", bucket, err)
+			return err
+		}
+	}
+
+	expireDays, expirePrefix, err := parseExpiration(options)
+	if err != nil {
+		return err
+	}
+	if expireDays > 0 {
+		if err := d.c.SetBucketExpiration(bucket, expireDays, expirePrefix); err != nil {
+			glog.Warningf("Failed to set expiration rules on bucket %s: %s", bucket, err)
 			return err
 		}
 	}
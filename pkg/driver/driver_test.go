@@ -1 +1,149 @@
 package driver
+
+import (
+	"archive/tar"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/go-plugins-helpers/volume"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+func TestCreateSeedWithoutLock(t *testing.T) {
+	ts := newBucketServer()
+	defer ts.Close()
+	release := make(chan struct{})
+	seed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		tar.NewWriter(w).Close()
+	}))
+	defer seed.Close()
+
+	d := NewMinioDriver(nil, false)
+	defer removeMountpoints(d)
+	options := map[string]string{
+		"server":    strings.TrimPrefix(ts.URL, "http://"),
+		"accessKey": "access",
+		"secretKey": "secret",
+		"bucket":    "data-1",
+		"seedFrom":  seed.URL,
+	}
+	// The steps of Create are run the way it runs them, without checking
+	// the mount backend.
+	d.m.Lock()
+	p, err := d.prepareVolume("seeded", options)
+	if err == nil {
+		err = d.createVolumeClient(p)
+	}
+	d.m.Unlock()
+	if err != nil {
+		t.Fatalf("An error occured while preparing the volume: %s", err)
+	}
+	populated := make(chan error, 1)
+	go func() {
+		populated <- d.populateVolume("seeded", p)
+	}()
+
+	listed := make(chan volume.Response, 1)
+	go func() {
+		listed <- d.List(volume.Request{})
+	}()
+	select {
+	case resp := <-listed:
+		if len(resp.Volumes) != 0 {
+			t.Errorf("Expected the seeded volume not to be listed yet, got %#v", resp.Volumes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected List not to wait for the seed")
+	}
+	d.m.Lock()
+	if _, err := d.prepareVolume("seeded", options); err == nil || !strings.Contains(err.Error(), "already being created") {
+		t.Errorf("Expected a volume to be created once at a time, got %v", err)
+	}
+	d.m.Unlock()
+
+	close(release)
+	if err := <-populated; err != nil {
+		t.Fatalf("An error occured while seeding the volume: %s", err)
+	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	if err := d.registerVolume("seeded", p); err != nil {
+		t.Fatalf("An error occured while registering the volume: %s", err)
+	}
+	if v := d.volumes["seeded"]; v == nil || v.bucketName != "data-1" {
+		t.Errorf("Expected the seeded volume to be registered, got %#v", v)
+	}
+}
+
+func TestCreateSeedCustomerKey(t *testing.T) {
+	d := NewMinioDriver(nil, false)
+	options := map[string]string{
+		"server":             "localhost:9000",
+		"accessKey":          "access",
+		"secretKey":          "secret",
+		"seedFrom":           "/srv/seed.tar",
+		"sse":                "c",
+		"sseCustomerKeyFile": "/run/secrets/sse",
+	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	if _, err := d.prepareVolume("seeded", options); err == nil || !strings.Contains(err.Error(), "sse=c") {
+		t.Errorf("Expected seeding an SSE-C volume to be refused, got %v", err)
+	}
+	if _, ok := d.creating["seeded"]; ok {
+		t.Errorf("Expected the refused volume not to be marked as being created")
+	}
+}
+
+func TestCreateExistingBucketConfig(t *testing.T) {
+	d := NewMinioDriver(nil, false)
+	options := map[string]string{
+		"server":     "localhost:9000",
+		"accessKey":  "access",
+		"secretKey":  "secret",
+		"bucket":     "data-1",
+		"expireDays": "7",
+		"versioning": "enabled",
+	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	p, err := d.prepareVolume("existing", options)
+	if err != nil {
+		t.Fatalf("An error occured while preparing the volume: %s", err)
+	}
+	if p.expireDays != 0 || p.expirePrefix != "" {
+		t.Errorf("Expected no expiration to be recorded for an existing bucket, got %d days under %q", p.expireDays, p.expirePrefix)
+	}
+	if p.versioning != "" || p.objectLock != "" {
+		t.Errorf("Expected no versioning nor object lock to be recorded for an existing bucket, got %q and %q", p.versioning, p.objectLock)
+	}
+}
+
+func TestCloneSourceVolume(t *testing.T) {
+	d := NewMinioDriver(nil, false)
+	c, err := client.NewMinioClient("localhost:9000", "access", "secret", "data-1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newVolume("miniovol-1", "/mnt/miniovol-1", "data-1")
+	v.c = c
+	d.volumes["source"] = v
+
+	options := map[string]string{"server": "localhost:9000", "accessKey": "access", "cloneFrom": "source"}
+	if bucket, _, err := d.cloneSource(options); err != nil || bucket != "data-1" {
+		t.Errorf("Expected the clone of bucket data-1, got %s and %v", bucket, err)
+	}
+	other := withOption(options, "server", "backup:9000")
+	if _, _, err := d.cloneSource(other); err == nil || !strings.Contains(err.Error(), "another server") {
+		t.Errorf("Expected clones across servers to be refused, got %v", err)
+	}
+	v.sse = client.SSEC
+	if _, _, err := d.cloneSource(options); err == nil || !strings.Contains(err.Error(), "sse=c") {
+		t.Errorf("Expected clones of SSE-C volumes to be refused, got %v", err)
+	}
+}
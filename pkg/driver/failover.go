@@ -0,0 +1,129 @@
+package driver
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// EndpointCheckInterval is how often the endpoints of the volumes with
+// several endpoints are checked.
+const EndpointCheckInterval = 30 * time.Second
+
+// WatchEndpoints checks the endpoints of the volumes every interval and fails
+// the mounted volumes over to a healthy endpoint when theirs fails, until
+// stopCh is closed.
+func (d *MinioDriver) WatchEndpoints(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			d.checkEndpoints()
+		}
+	}
+}
+
+// volumeEndpoint returns the endpoint of the client of a volume.
+func volumeEndpoint(v *minioVolume) endpoint {
+	return endpoint{
+		server:    v.c.ServerURI,
+		accessKey: v.c.AccesKeyID,
+		secretKey: v.c.SecretAccessKey,
+		secure:    v.c.Secure,
+		opts:      v.c.Options,
+	}
+}
+
+// checkEndpoints records the health of the endpoints of the volumes with
+// several endpoints in their clients, and fails the mounted volumes over.
+// The endpoints are checked without holding the driver lock, since an
+// unreachable endpoint takes up to probeTimeout to fail.
+func (d *MinioDriver) checkEndpoints() {
+	d.m.RLock()
+	var replicas []endpoint
+	seen := make(map[endpoint]bool)
+	for _, v := range d.volumes {
+		if len(v.c.Endpoints()) < 2 {
+			continue
+		}
+		for _, replica := range volumeEndpoint(v).replicas() {
+			if !seen[replica] {
+				seen[replica] = true
+				replicas = append(replicas, replica)
+			}
+		}
+	}
+	d.m.RUnlock()
+	if len(replicas) == 0 {
+		return
+	}
+
+	healthy := make(map[endpoint]bool, len(replicas))
+	for _, replica := range replicas {
+		check := checkEndpoint(replica)
+		if !check.Healthy {
+			glog.Warningf("Endpoint %s is unhealthy: %s", check.Name, check.Error)
+		}
+		healthy[replica] = check.Healthy
+	}
+
+	mounted, err := readMounts()
+	if err != nil {
+		glog.Warningf("Failed to read mounts while checking endpoints: %s", err)
+		return
+	}
+
+	d.m.Lock()
+	defer d.m.Unlock()
+	for name, v := range d.volumes {
+		if len(v.c.Endpoints()) < 2 {
+			continue
+		}
+		for _, replica := range volumeEndpoint(v).replicas() {
+			if h, ok := healthy[replica]; ok {
+				v.c.SetEndpointHealth(replica.server, h)
+			}
+		}
+		if mounted[v.mountpoint] {
+			d.failover(name, v)
+		}
+	}
+}
+
+// failover remounts a mounted volume from the current endpoint of its client
+// when the endpoint it's mounted from failed. A volume with active mounts
+// stays mounted from the failed endpoint until the last of them is unmounted,
+// it's mounted from a healthy endpoint next time. The caller must hold the
+// driver lock.
+func (d *MinioDriver) failover(name string, v *minioVolume) {
+	if v.endpoint == "" || v.c.EndpointHealthy(v.endpoint) {
+		return
+	}
+	target := v.c.Endpoint()
+	if !v.c.EndpointHealthy(target) {
+		glog.Warningf("Endpoint %s of volume %s failed and no other endpoint is healthy", v.endpoint, name)
+		return
+	}
+
+	if len(v.mounts) > 0 {
+		glog.Warningf("Endpoint %s of volume %s failed, it stays mounted from it until its %d active mounts are unmounted", v.endpoint, name, len(v.mounts))
+		v.remountPending = true
+		d.metrics.failover(true)
+		return
+	}
+
+	glog.Warningf("Endpoint %s of volume %s failed, remounting it from %s", v.endpoint, name, target)
+	if err := d.unmountVolume(v); err != nil {
+		glog.Warningf("Failed to unmount volume %s from %s: %s", name, v.endpoint, err)
+		return
+	}
+	if err := d.mountVolume(v); err != nil {
+		glog.Warningf("Failed to remount volume %s from %s: %s", name, target, err)
+		return
+	}
+	d.metrics.failover(false)
+	glog.V(0).Infof("Volume %s failed over to %s", name, v.endpoint)
+}
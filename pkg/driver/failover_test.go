@@ -0,0 +1,58 @@
+package driver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+func TestCheckEndpoints(t *testing.T) {
+	up := newTestServer("access")
+	defer up.Close()
+	down := newTestServer("access")
+	down.Close()
+	upServer := strings.TrimPrefix(up.URL, "http://")
+	downServer := strings.TrimPrefix(down.URL, "http://")
+
+	c, err := client.NewMinioClientWithOptions(downServer+","+upServer, "access", "secret", "testbucket", false, client.Options{})
+	if err != nil {
+		t.Fatalf("An error occured while creating the client: %s", err)
+	}
+	d := NewMinioDriver(nil, false)
+	v := newVolume("miniovol-test", "/nonexistent/miniovol-test", "testbucket")
+	v.c = c
+	d.volumes["test"] = v
+
+	d.checkEndpoints()
+	if endpoint := c.Endpoint(); endpoint != upServer {
+		t.Errorf("Expected the volume to use %s, got %s", upServer, endpoint)
+	}
+	if status := d.volumeStatus(v); status["endpoint"] != upServer {
+		t.Errorf("Expected endpoint %s in the status, got %#v", upServer, status)
+	}
+
+	v.endpoint = downServer
+	v.mounts["a"] = struct{}{}
+	d.failover("test", v)
+	if v.endpoint != downServer || !v.remountPending {
+		t.Errorf("Expected the volume in use to stay on %s until it's unmounted, got %#v", downServer, v)
+	}
+	if metrics := d.Metrics(); metrics.BlockedFailovers != 1 || metrics.Failovers != 0 {
+		t.Errorf("Expected a blocked failover, got %#v", metrics)
+	}
+
+	// Without active mounts the volume is unmounted, which fails since the
+	// mountpoint doesn't exist, and isn't counted as blocked.
+	delete(v.mounts, "a")
+	d.failover("test", v)
+	if metrics := d.Metrics(); metrics.BlockedFailovers != 1 || metrics.Failovers != 0 {
+		t.Errorf("Expected no blocked failover for a volume without mounts, got %#v", metrics)
+	}
+
+	v.endpoint = upServer
+	d.failover("test", v)
+	if metrics := d.Metrics(); metrics.BlockedFailovers != 1 {
+		t.Errorf("Expected no failover from a healthy endpoint, got %#v", metrics)
+	}
+}
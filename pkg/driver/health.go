@@ -0,0 +1,189 @@
+package driver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+const (
+	fuseDevice  = "/dev/fuse"
+	minfsHelper = "mount.minfs"
+
+	// probeTimeout bounds how long an endpoint check can take, so that an
+	// unreachable server doesn't hang the caller.
+	probeTimeout = 5 * time.Second
+)
+
+// HealthCheck is the result of a single health check.
+type HealthCheck struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Health is the health of the driver and of the backends it depends on.
+type Health struct {
+	Healthy bool          `json:"healthy"`
+	Checks  []HealthCheck `json:"checks"`
+}
+
+// endpoint is a server along with the credentials used to reach it.
+type endpoint struct {
+	server    string
+	accessKey string
+	secretKey string
+	secure    bool
+	opts      client.Options
+}
+
+// Health checks that the mount backend is usable and that the configured
+// server, and the server of the latest created volume, accept the
+// credentials they are used with.
+func (d *MinioDriver) Health() Health {
+	d.m.RLock()
+	endpoints := d.endpoints()
+	d.m.RUnlock()
+
+	checks := checkMountBackend()
+	for _, e := range endpoints {
+		for _, replica := range e.replicas() {
+			checks = append(checks, checkEndpoint(replica))
+		}
+	}
+	return newHealth(checks)
+}
+
+// endpoints returns the distinct servers known to the driver.
+func (d *MinioDriver) endpoints() []endpoint {
+	var endpoints []endpoint
+	if d.cfg.Server != "" {
+		endpoints = append(endpoints, endpoint{
+			server:    d.cfg.Server,
+			accessKey: d.cfg.AccessKey,
+			secretKey: d.cfg.SecretKey,
+			secure:    d.cfg.Secure,
+		})
+	}
+	if d.server != "" && d.server != d.cfg.Server {
+		endpoints = append(endpoints, endpoint{
+			server:    d.server,
+			accessKey: d.accessKey,
+			secretKey: d.secretKey,
+			secure:    d.c != nil && d.c.Secure,
+			opts:      d.clientOptions(),
+		})
+	}
+	return endpoints
+}
+
+// replicas returns an endpoint for each of the servers of e, when it has
+// several.
+func (e endpoint) replicas() []endpoint {
+	servers := client.ParseEndpoints(e.server)
+	if len(servers) < 2 {
+		return []endpoint{e}
+	}
+	replicas := make([]endpoint, 0, len(servers))
+	for _, server := range servers {
+		replica := e
+		replica.server = server
+		replicas = append(replicas, replica)
+	}
+	return replicas
+}
+
+// clientOptions returns the provider options of the current client.
+func (d *MinioDriver) clientOptions() client.Options {
+	if d.c == nil {
+		return client.Options{}
+	}
+	return d.c.Options
+}
+
+// checkBackend returns an error describing every failed check of the mount
+// backend and of the server set in the volume options.
+func checkBackend(options map[string]string) error {
+	checks := checkMountBackend()
+	if server, err := checkParam("server", options); err == nil {
+		_, err := checkParam("secure", options)
+		// Invalid provider options are reported when the client is created.
+		opts, _ := parseClientOptions(options)
+		checks = append(checks, checkEndpoint(endpoint{
+			server:    server,
+			accessKey: options["accessKey"],
+			secretKey: options["secretKey"],
+			secure:    err == nil,
+			opts:      opts,
+		}))
+	}
+
+	var failed []string
+	for _, check := range checks {
+		if !check.Healthy {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Error))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("backend is unhealthy: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// checkMountBackend checks that volumes can be mounted with minfs.
+func checkMountBackend() []HealthCheck {
+	return []HealthCheck{
+		newHealthCheck(minfsHelper, func() error {
+			_, err := exec.LookPath(minfsHelper)
+			return err
+		}),
+		newHealthCheck(fuseDevice, func() error {
+			_, err := os.Stat(fuseDevice)
+			return err
+		}),
+	}
+}
+
+// checkEndpoint checks that a server is reachable and accepts the
+// credentials by listing its buckets.
+func checkEndpoint(e endpoint) HealthCheck {
+	return newHealthCheck(e.server, func() error {
+		c, err := client.NewMinioClientWithOptions(e.server, e.accessKey, e.secretKey, "", e.secure, e.opts)
+		if err != nil {
+			return err
+		}
+		c.SetTransport(&http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			Dial:                  (&net.Dialer{Timeout: probeTimeout}).Dial,
+			TLSHandshakeTimeout:   probeTimeout,
+			ResponseHeaderTimeout: probeTimeout,
+		})
+		_, err = c.Client.ListBuckets()
+		return err
+	})
+}
+
+func newHealthCheck(name string, check func() error) HealthCheck {
+	hc := HealthCheck{Name: name, Healthy: true}
+	if err := check(); err != nil {
+		hc.Healthy = false
+		hc.Error = err.Error()
+	}
+	return hc
+}
+
+func newHealth(checks []HealthCheck) Health {
+	health := Health{Healthy: true, Checks: checks}
+	for _, check := range checks {
+		if !check.Healthy {
+			health.Healthy = false
+		}
+	}
+	return health
+}
@@ -0,0 +1,73 @@
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const listBuckets = `<?xml version="1.0" encoding="UTF-8"?>
+<ListAllMyBucketsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+<Owner><ID>miniovol</ID><DisplayName>miniovol</DisplayName></Owner>
+<Buckets></Buckets>
+</ListAllMyBucketsResult>`
+
+func newTestServer(accessKey string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential="+accessKey+"/") {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<Error><Code>InvalidAccessKeyId</Code><Message>The access key ID you provided does not exist.</Message></Error>`))
+			return
+		}
+		w.Write([]byte(listBuckets))
+	}))
+}
+
+func TestCheckEndpoint(t *testing.T) {
+	ts := newTestServer("access")
+	defer ts.Close()
+	server := strings.TrimPrefix(ts.URL, "http://")
+
+	check := checkEndpoint(endpoint{server: server, accessKey: "access", secretKey: "secret"})
+	if !check.Healthy || check.Name != server {
+		t.Errorf("Expected healthy endpoint %s, got %#v", server, check)
+	}
+
+	check = checkEndpoint(endpoint{server: server, accessKey: "wrong", secretKey: "secret"})
+	if check.Healthy || check.Error == "" {
+		t.Errorf("Expected endpoint with wrong credentials to be unhealthy, got %#v", check)
+	}
+
+	ts.Close()
+	check = checkEndpoint(endpoint{server: server, accessKey: "access", secretKey: "secret"})
+	if check.Healthy {
+		t.Errorf("Expected unreachable endpoint to be unhealthy, got %#v", check)
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	d := NewMinioDriver(nil, false)
+	d.SetConfig(Config{Server: "a:9000", AccessKey: "access", SecretKey: "secret"}, "")
+	d.server = "b:9000"
+
+	endpoints := d.endpoints()
+	if len(endpoints) != 2 || endpoints[0].server != "a:9000" || endpoints[1].server != "b:9000" {
+		t.Errorf("Expected endpoints a:9000 and b:9000, got %#v", endpoints)
+	}
+
+	d.server = "a:9000"
+	if endpoints := d.endpoints(); len(endpoints) != 1 {
+		t.Errorf("Expected a single endpoint, got %#v", endpoints)
+	}
+}
+
+func TestNewHealth(t *testing.T) {
+	if health := newHealth([]HealthCheck{{Name: "a", Healthy: true}}); !health.Healthy {
+		t.Errorf("Expected healthy, got %#v", health)
+	}
+	health := newHealth([]HealthCheck{{Name: "a", Healthy: true}, {Name: "b"}})
+	if health.Healthy {
+		t.Errorf("Expected unhealthy, got %#v", health)
+	}
+}
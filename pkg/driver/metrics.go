@@ -0,0 +1,158 @@
+package driver
+
+import (
+	"sync"
+
+	"github.com/docker/go-plugins-helpers/volume"
+	"github.com/golang/glog"
+)
+
+// Metrics holds the counters of the driver exposed by the admin API.
+type Metrics struct {
+	Requests map[string]int64 `json:"requests"`
+	Errors   map[string]int64 `json:"errors"`
+	Volumes  int              `json:"volumes"`
+	Mounted  int              `json:"mounted"`
+
+	// Failovers counts the volumes remounted from another endpoint, and
+	// BlockedFailovers the ones that had active mounts and stayed mounted.
+	Failovers        int64 `json:"failovers"`
+	BlockedFailovers int64 `json:"blockedFailovers"`
+}
+
+// metrics counts the docker requests served by the driver. It has its own
+// lock so that reading the metrics never waits for a slow request.
+type metrics struct {
+	m                sync.Mutex
+	requests         map[string]int64
+	errors           map[string]int64
+	failovers        int64
+	blockedFailovers int64
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		requests: make(map[string]int64),
+		errors:   make(map[string]int64),
+	}
+}
+
+// observe records a request and passes its response through.
+func (m *metrics) observe(op string, resp volume.Response) volume.Response {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	m.requests[op]++
+	if resp.Err != "" {
+		m.errors[op]++
+	}
+	return resp
+}
+
+// failover records a failover, blocked when the volume had active mounts.
+func (m *metrics) failover(blocked bool) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	if blocked {
+		m.blockedFailovers++
+		return
+	}
+	m.failovers++
+}
+
+// Metrics returns a snapshot of the counters of the driver.
+func (d *MinioDriver) Metrics() Metrics {
+	d.metrics.m.Lock()
+	metrics := Metrics{
+		Requests:         make(map[string]int64, len(d.metrics.requests)),
+		Errors:           make(map[string]int64, len(d.metrics.errors)),
+		Failovers:        d.metrics.failovers,
+		BlockedFailovers: d.metrics.blockedFailovers,
+	}
+	for op, n := range d.metrics.requests {
+		metrics.Requests[op] = n
+	}
+	for op, n := range d.metrics.errors {
+		metrics.Errors[op] = n
+	}
+	d.metrics.m.Unlock()
+
+	d.m.RLock()
+	defer d.m.RUnlock()
+	metrics.Volumes = len(d.volumes)
+	for _, v := range d.volumes {
+		if len(v.mounts) > 0 {
+			metrics.Mounted++
+		}
+	}
+	return metrics
+}
+
+// instrumentedDriver counts the requests served by a MinioDriver, tracks them
+// for shutdown and persists the registry after the ones that change it.
+type instrumentedDriver struct {
+	*MinioDriver
+}
+
+// Instrumented returns the driver wrapped so that the requests it serves are
+// counted in its metrics. It is the driver that should be handed to docker.
+func (d *MinioDriver) Instrumented() volume.Driver {
+	return instrumentedDriver{d}
+}
+
+// persistedOps are the requests that change the registry of the driver.
+var persistedOps = map[string]bool{
+	"create":  true,
+	"remove":  true,
+	"mount":   true,
+	"unmount": true,
+}
+
+// serve runs a request unless the driver is shutting down.
+func (i instrumentedDriver) serve(op string, handle func() volume.Response) volume.Response {
+	if !i.inflight.begin() {
+		return i.metrics.observe(op, volume.Response{Err: errShuttingDown})
+	}
+	defer i.inflight.done()
+
+	resp := handle()
+	if resp.Err == "" && persistedOps[op] {
+		if err := i.SaveState(); err != nil {
+			glog.Warningf("Failed to save state after %s: %s", op, err)
+		}
+	}
+	return i.metrics.observe(op, resp)
+}
+
+func (i instrumentedDriver) Create(r volume.Request) volume.Response {
+	return i.serve("create", func() volume.Response { return i.MinioDriver.Create(r) })
+}
+
+func (i instrumentedDriver) List(r volume.Request) volume.Response {
+	return i.serve("list", func() volume.Response { return i.MinioDriver.List(r) })
+}
+
+func (i instrumentedDriver) Get(r volume.Request) volume.Response {
+	return i.serve("get", func() volume.Response { return i.MinioDriver.Get(r) })
+}
+
+func (i instrumentedDriver) Remove(r volume.Request) volume.Response {
+	return i.serve("remove", func() volume.Response { return i.MinioDriver.Remove(r) })
+}
+
+func (i instrumentedDriver) Path(r volume.Request) volume.Response {
+	return i.serve("path", func() volume.Response { return i.MinioDriver.Path(r) })
+}
+
+func (i instrumentedDriver) Mount(r volume.MountRequest) volume.Response {
+	return i.serve("mount", func() volume.Response { return i.MinioDriver.Mount(r) })
+}
+
+func (i instrumentedDriver) Unmount(r volume.UnmountRequest) volume.Response {
+	return i.serve("unmount", func() volume.Response { return i.MinioDriver.Unmount(r) })
+}
+
+func (i instrumentedDriver) Capabilities(r volume.Request) volume.Response {
+	return i.serve("capabilities", func() volume.Response { return i.MinioDriver.Capabilities(r) })
+}
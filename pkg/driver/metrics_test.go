@@ -0,0 +1,28 @@
+package driver
+
+import (
+	"testing"
+
+	"github.com/docker/go-plugins-helpers/volume"
+)
+
+func TestInstrumentedDriver(t *testing.T) {
+	d := NewMinioDriver(nil, false)
+	d.volumes["test"] = newVolume("test", "/mnt/test", "testbucket")
+	i := d.Instrumented()
+
+	i.Path(volume.Request{Name: "test"})
+	i.Path(volume.Request{Name: "missing"})
+	i.Capabilities(volume.Request{})
+
+	metrics := d.Metrics()
+	if metrics.Requests["path"] != 2 || metrics.Errors["path"] != 1 {
+		t.Errorf("Expected 2 path requests and 1 error, got %#v", metrics)
+	}
+	if metrics.Requests["capabilities"] != 1 || metrics.Errors["capabilities"] != 0 {
+		t.Errorf("Expected 1 capabilities request without errors, got %#v", metrics)
+	}
+	if metrics.Volumes != 1 || metrics.Mounted != 0 {
+		t.Errorf("Expected 1 unmounted volume, got %#v", metrics)
+	}
+}
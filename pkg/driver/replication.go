@@ -0,0 +1,275 @@
+package driver
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+// MirrorInterval is how often the volumes created with replicateTo are
+// mirrored in full. It catches the changes the bucket notifications missed
+// and replicates the volumes of providers that have no notifications.
+const MirrorInterval = 5 * time.Minute
+
+// replicator mirrors the bucket of a volume to the bucket of the same name
+// behind a profile. Changes are mirrored as the bucket notifications report
+// them, and the whole bucket every interval.
+type replicator struct {
+	profile  string
+	source   *client.MinioClient
+	target   *client.MinioClient
+	interval time.Duration
+	stopCh   chan struct{}
+	now      func() time.Time
+
+	m sync.Mutex
+	// pending holds the objects whose last change couldn't be mirrored yet,
+	// along with when the change was seen.
+	pending map[string]time.Time
+	// synced is when the target last caught up with a full mirror, failing
+	// whether the mirrors failed since, and lastErr the last error.
+	synced  time.Time
+	failing bool
+	lastErr string
+}
+
+func newReplicator(profile string, source, target *client.MinioClient, interval time.Duration) *replicator {
+	return &replicator{
+		profile:  profile,
+		source:   source,
+		target:   target,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+		now:      time.Now,
+		pending:  make(map[string]time.Time),
+	}
+}
+
+// start runs the replicator in the background until it's stopped.
+func (r *replicator) start() *replicator {
+	go r.run()
+	return r
+}
+
+// stop stops the replicator without waiting for it. A mirror in progress
+// isn't interrupted and runs to its end, alongside the replicator that may
+// replace this one, since both only copy what differs.
+func (r *replicator) stop() {
+	close(r.stopCh)
+}
+
+func (r *replicator) run() {
+	changes := r.source.Changes(r.stopCh)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.mirror()
+	for {
+		select {
+		case <-r.stopCh:
+			return
+		case <-ticker.C:
+			r.mirror()
+		case change, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			if change.Err != nil {
+				glog.Warningf("No bucket notifications for bucket %s, mirroring it every %s: %s",
+					r.source.BucketName, r.interval, change.Err)
+				continue
+			}
+			r.mirrorChange(change.Key)
+		}
+	}
+}
+
+// mirror mirrors the whole bucket, which clears the pending changes.
+func (r *replicator) mirror() {
+	started := r.now()
+	result, err := r.source.Mirror(r.target)
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	if err != nil {
+		glog.Warningf("Failed to mirror bucket %s to profile %s: %s", r.source.BucketName, r.profile, err)
+		r.failing = true
+		r.lastErr = err.Error()
+		return
+	}
+	glog.V(1).Infof("Mirrored bucket %s to profile %s: %#v", r.source.BucketName, r.profile, result)
+	for key, seen := range r.pending {
+		if seen.Before(started) {
+			delete(r.pending, key)
+		}
+	}
+	r.synced = started
+	r.failing = false
+	r.lastErr = ""
+}
+
+// mirrorChange mirrors the object key, it stays pending until the next
+// mirror when that fails.
+func (r *replicator) mirrorChange(key string) {
+	seen := r.now()
+	err := r.source.MirrorObject(r.target, key)
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	if err != nil {
+		glog.Warningf("Failed to mirror %s of bucket %s to profile %s: %s", key, r.source.BucketName, r.profile, err)
+		if _, ok := r.pending[key]; !ok {
+			r.pending[key] = seen
+		}
+		r.lastErr = err.Error()
+		return
+	}
+	delete(r.pending, key)
+}
+
+// status returns the replication status of the volume. The lag is how long
+// the oldest change not mirrored yet has been waiting, or for how long the
+// mirrors have been failing, zero when the target is caught up.
+func (r *replicator) status() map[string]interface{} {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	status := map[string]interface{}{
+		"target":  r.profile,
+		"pending": len(r.pending),
+	}
+	var oldest time.Time
+	for _, seen := range r.pending {
+		if oldest.IsZero() || seen.Before(oldest) {
+			oldest = seen
+		}
+	}
+	if r.failing {
+		oldest = r.synced
+	}
+	if r.lastErr != "" {
+		status["error"] = r.lastErr
+	}
+	if !r.synced.IsZero() {
+		status["lastSync"] = r.synced
+	}
+	switch {
+	case r.synced.IsZero():
+		// The target never caught up, the first mirror is running or failed.
+		status["lag"] = "unknown"
+	case oldest.IsZero():
+		status["lag"] = time.Duration(0).String()
+	default:
+		status["lag"] = r.now().Sub(oldest).String()
+	}
+	return status
+}
+
+// replicationTarget returns a client for the bucket of the same name as the
+// bucket of source behind a profile, creating the bucket when it doesn't
+// exist. The profile must point to another server, a bucket mirrored to
+// itself would be copied over and over. The mirror removes the objects the
+// volume doesn't have, so an existing bucket is only used when created is
+// set, meaning that the plugin created it for the volume before. The replica
+// is encrypted like the volume. The driver lock must not be held, it's only
+// taken to read the profile.
+func (d *MinioDriver) replicationTarget(profile string, source *client.MinioClient, created bool) (*client.MinioClient, error) {
+	d.m.RLock()
+	_, ok := d.cfg.Profiles[profile]
+	options, err := d.cfg.withDefaults(map[string]string{"profile": profile})
+	d.m.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown replicateTo profile %s", profile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if options, err = withSecretKeyFile(options); err != nil {
+		return nil, err
+	}
+	c, err := newEndpointClient(options)
+	if err != nil {
+		return nil, err
+	}
+	if c.ServerURI == source.ServerURI {
+		return nil, fmt.Errorf("replicateTo profile %s points to the server of the volume", profile)
+	}
+	bucket := source.BucketName
+	exists, err := c.Client.BucketExists(bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check replica bucket %s: %s", bucket, err)
+	}
+	if exists && !created {
+		return nil, fmt.Errorf("replica bucket %s already exists behind profile %s and wasn't created by the plugin", bucket, profile)
+	}
+	c.Encryption = source.Encryption
+	if !exists {
+		if err := c.Client.MakeBucket(bucket, bucketRegion(c)); err != nil {
+			return nil, fmt.Errorf("failed to create replica bucket %s: %s", bucket, err)
+		}
+		if c.Encryption != nil {
+			if err := c.SetBucketEncryption(bucket, c.Encryption); err != nil {
+				return nil, fmt.Errorf("failed to set default encryption on replica bucket %s: %s", bucket, err)
+			}
+		}
+		glog.V(0).Infof("Created replica bucket %s with profile %s", bucket, profile)
+	}
+	c.BucketName = bucket
+	return c, nil
+}
+
+// startReplication starts mirroring a volume to target, the bucket returned
+// by replicationTarget for its replicateTo profile. The caller must hold the
+// driver lock.
+func startReplication(name string, v *minioVolume, target *client.MinioClient) {
+	v.replicaCreated = true
+	v.replicator = newReplicator(v.replicateTo, v.c, target, MirrorInterval).start()
+	glog.V(0).Infof("Replicating volume %s to profile %s", name, v.replicateTo)
+}
+
+// resumeReplication starts mirroring the restored volumes that are
+// replicated. The targets are resolved without holding the driver lock,
+// since the server of a profile may be unreachable.
+func (d *MinioDriver) resumeReplication(volumes map[string]*minioVolume) {
+	for name, v := range volumes {
+		d.m.RLock()
+		profile, source, created := v.replicateTo, v.c, v.replicaCreated
+		d.m.RUnlock()
+
+		target, err := d.replicationTarget(profile, source, created)
+		if err != nil {
+			glog.Warningf("Failed to replicate volume %s to profile %s: %s", name, profile, err)
+			continue
+		}
+		d.m.Lock()
+		if d.volumes[name] == v && v.replicator == nil {
+			startReplication(name, v, target)
+		}
+		d.m.Unlock()
+	}
+}
+
+// restartReplication restarts the replicator of a volume, if it is
+// replicated, with the current client of the volume. The caller must hold the
+// driver lock.
+func restartReplication(v *minioVolume) {
+	if v.replicator == nil {
+		return
+	}
+	v.replicator.stop()
+	v.replicator = newReplicator(v.replicateTo, v.c, v.replicator.target, v.replicator.interval).start()
+}
+
+// stopReplication stops mirroring a volume, if it is replicated. The caller
+// must hold the driver lock.
+func stopReplication(v *minioVolume) {
+	if v.replicator != nil {
+		v.replicator.stop()
+		v.replicator = nil
+	}
+}
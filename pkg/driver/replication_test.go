@@ -0,0 +1,118 @@
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/go-plugins-helpers/volume"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+func TestReplicatorStatus(t *testing.T) {
+	now := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := newReplicator("backup", nil, nil, MirrorInterval)
+	r.now = func() time.Time { return now }
+
+	if status := r.status(); status["lag"] != "unknown" || status["target"] != "backup" {
+		t.Errorf("Expected an unknown lag before the first mirror, got %v", status)
+	}
+
+	r.synced = now.Add(-time.Hour)
+	if status := r.status(); status["lag"] != "0s" {
+		t.Errorf("Expected no lag once caught up, got %v", status)
+	}
+
+	r.pending["a.txt"] = now.Add(-2 * time.Minute)
+	r.pending["b.txt"] = now.Add(-time.Minute)
+	r.lastErr = "connection refused"
+	if status := r.status(); status["lag"] != "2m0s" || status["pending"] != 2 || status["error"] != "connection refused" {
+		t.Errorf("Expected the lag of the oldest pending change, got %v", status)
+	}
+
+	r.failing = true
+	if status := r.status(); status["lag"] != "1h0m0s" {
+		t.Errorf("Expected the lag since the last mirror while mirrors fail, got %v", status)
+	}
+}
+
+func TestCreateReplicateToUnknownProfile(t *testing.T) {
+	d := NewMinioDriver(nil, false)
+	resp := d.Create(volume.Request{Name: "vol", Options: map[string]string{
+		"server":      "localhost:9000",
+		"accessKey":   "access",
+		"secretKey":   "secret",
+		"replicateTo": "backup",
+	}})
+	if !strings.Contains(resp.Err, "unknown replicateTo profile backup") {
+		t.Errorf("Expected an unknown replicateTo profile to be refused, got %q", resp.Err)
+	}
+}
+
+func TestReplicationTargetExistingBucket(t *testing.T) {
+	ts := newBucketServer()
+	defer ts.Close()
+
+	d := NewMinioDriver(nil, false)
+	d.cfg.Profiles = map[string]Profile{
+		"backup": {Server: strings.TrimPrefix(ts.URL, "http://"), AccessKey: "access", SecretKey: "secret"},
+	}
+	source, err := client.NewMinioClient("localhost:9000", "access", "secret", "data-1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.Encryption = &client.Encryption{Mode: client.SSEKMS, KMSKeyID: "volumes"}
+	if _, err := d.replicationTarget("backup", source, false); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected a bucket the plugin didn't create not to be mirrored to, got %v", err)
+	}
+	target, err := d.replicationTarget("backup", source, true)
+	if err != nil {
+		t.Fatalf("An error occured while restoring the replica: %s", err)
+	}
+	if target.BucketName != "data-1" {
+		t.Errorf("Expected the replica bucket data-1, got %s", target.BucketName)
+	}
+	if target.Encryption != source.Encryption {
+		t.Errorf("Expected the replica to be encrypted like the volume, got %#v", target.Encryption)
+	}
+}
+
+func TestResumeReplicationWithoutLock(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	d := NewMinioDriver(nil, false)
+	d.cfg.Profiles = map[string]Profile{
+		"backup": {Server: strings.TrimPrefix(ts.URL, "http://"), AccessKey: "access", SecretKey: "secret"},
+	}
+	c, err := client.NewMinioClient("localhost:9000", "access", "secret", "data-1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newVolume("miniovol-1", "/mnt/miniovol-1", "data-1")
+	v.c = c
+	v.replicateTo = "backup"
+	d.volumes["test"] = v
+
+	go d.resumeReplication(map[string]*minioVolume{"test": v})
+	listed := make(chan volume.Response, 1)
+	go func() {
+		listed <- d.List(volume.Request{})
+	}()
+	select {
+	case resp := <-listed:
+		if len(resp.Volumes) != 1 {
+			t.Errorf("Expected the restored volume to be listed, got %#v", resp.Volumes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected List not to wait for the replica server")
+	}
+}
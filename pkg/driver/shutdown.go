@@ -0,0 +1,139 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Policies for the mounts of the volumes when the driver shuts down.
+const (
+	// MountsKeep leaves the volumes mounted, so that the containers using
+	// them keep working and the mounts are adopted again on restart.
+	MountsKeep = "keep"
+	// MountsUnmount unmounts the volumes. Their mount IDs are persisted, so
+	// that they are mounted again on restart.
+	MountsUnmount = "unmount"
+)
+
+// errShuttingDown is returned for the requests received during shutdown.
+const errShuttingDown = "the plugin is shutting down"
+
+// inflight tracks the requests being served, so that shutdown can wait for
+// them to finish.
+type inflight struct {
+	m       sync.Mutex
+	wg      sync.WaitGroup
+	stopped bool
+}
+
+// begin records a new request. It returns false once the tracker has been
+// stopped, in which case the request must be refused.
+func (f *inflight) begin() bool {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.stopped {
+		return false
+	}
+	f.wg.Add(1)
+	return true
+}
+
+// done records the end of a request.
+func (f *inflight) done() {
+	f.wg.Done()
+}
+
+// stop refuses new requests and waits for the ones in flight to finish, up
+// to timeout. It returns false if the timeout was reached.
+func (f *inflight) stop(timeout time.Duration) bool {
+	f.m.Lock()
+	f.stopped = true
+	f.m.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Shutdown refuses new requests, waits up to timeout for the ones in flight,
+// applies the mounts policy and persists the registry of the driver. It
+// gives up once timeout has passed, since a request stuck on the server
+// would otherwise block the shutdown forever.
+func (d *MinioDriver) Shutdown(timeout time.Duration, policy string) error {
+	if policy != MountsKeep && policy != MountsUnmount {
+		return fmt.Errorf("unknown mounts policy %s", policy)
+	}
+
+	deadline := time.Now().Add(timeout)
+	glog.V(0).Infof("Shutting down, waiting up to %s for requests in flight", timeout)
+	if !d.inflight.stop(timeout) {
+		glog.Warningf("Requests still in flight after %s", timeout)
+	}
+
+	// Leave saving the state a moment even if the requests used up all of
+	// the timeout, it's quick unless the driver lock is held by one of them.
+	remaining := deadline.Sub(time.Now())
+	if remaining < time.Second {
+		remaining = time.Second
+	}
+	finished := make(chan error, 1)
+	go func() {
+		d.m.Lock()
+		defer d.m.Unlock()
+
+		finished <- d.shutdown(policy)
+	}()
+	select {
+	case err := <-finished:
+		return err
+	case <-time.After(remaining):
+		return fmt.Errorf("shutdown timed out after %s, state was not saved", timeout)
+	}
+}
+
+// shutdown applies the mounts policy and persists the registry. The caller
+// must hold the driver lock.
+func (d *MinioDriver) shutdown(policy string) error {
+	var errs []string
+	for _, v := range d.volumes {
+		stopReplication(v)
+	}
+	if policy == MountsUnmount {
+		mounted, err := readMounts()
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		for name, v := range d.volumes {
+			if !mounted[v.mountpoint] {
+				continue
+			}
+			if err := d.unmountVolume(v); err != nil {
+				errs = append(errs, fmt.Sprintf("unmounting %s: %s", name, err))
+				continue
+			}
+			glog.V(0).Infof("Unmounted volume %s", name)
+		}
+	}
+	if err := d.saveState(); err != nil {
+		errs = append(errs, fmt.Sprintf("saving state: %s", err))
+	} else if d.statePath != "" {
+		glog.V(0).Infof("Saved state of %d volumes to %s", len(d.volumes), d.statePath)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("shutdown failed: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
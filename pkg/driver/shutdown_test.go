@@ -0,0 +1,57 @@
+package driver
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/docker/go-plugins-helpers/volume"
+)
+
+func TestShutdownRefusesRequests(t *testing.T) {
+	d := NewMinioDriver(nil, false)
+	if err := d.Shutdown(time.Second, MountsKeep); err != nil {
+		t.Fatalf("An error occured while shutting down: %s", err)
+	}
+	resp := d.Instrumented().Capabilities(volume.Request{})
+	if resp.Err != errShuttingDown {
+		t.Errorf("Expected request to be refused, got %#v", resp)
+	}
+}
+
+func TestShutdownWaitsForRequests(t *testing.T) {
+	d, dir := newStateDriver(t)
+	defer os.RemoveAll(dir)
+
+	if !d.inflight.begin() {
+		t.Fatal("Expected request to be accepted")
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		d.inflight.done()
+	}()
+	if err := d.Shutdown(time.Second, MountsKeep); err != nil {
+		t.Fatalf("An error occured while shutting down: %s", err)
+	}
+	if _, err := os.Stat(d.statePath); err != nil {
+		t.Errorf("Expected state to be saved, got %s", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	d := NewMinioDriver(nil, false)
+	d.inflight.begin()
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if err := d.Shutdown(10*time.Millisecond, MountsKeep); err == nil {
+		t.Error("Expected shutdown to time out")
+	}
+}
+
+func TestShutdownPolicy(t *testing.T) {
+	d := NewMinioDriver(nil, false)
+	if err := d.Shutdown(time.Second, "detach"); err == nil {
+		t.Error("Expected an unknown mounts policy to fail")
+	}
+}
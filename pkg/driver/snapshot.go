@@ -0,0 +1,100 @@
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+const snapshotPrefix = "miniosnap-"
+
+// Snapshot is a point in time copy of the bucket of a volume. A snapshot can
+// be restored by creating a new volume with cloneFromBucket set to its
+// bucket.
+type Snapshot struct {
+	Bucket  string    `json:"bucket"`
+	Created time.Time `json:"created"`
+	Objects int64     `json:"objects"`
+	Bytes   int64     `json:"bytes"`
+}
+
+// CreateSnapshot copies the bucket of a volume into a new bucket, which is
+// encrypted like the volume. The driver lock is not held during the copy, so
+// the volume stays usable meanwhile. The bucket is removed if the snapshot
+// fails.
+func (d *MinioDriver) CreateSnapshot(name string) (Snapshot, error) {
+	d.m.RLock()
+	v, exists := d.volumes[name]
+	if !exists {
+		d.m.RUnlock()
+		return Snapshot{}, newErrVolNotFound(name)
+	}
+	source := v.bucketName
+	c := *v.c
+	d.m.RUnlock()
+
+	if c.Encryption != nil && c.Encryption.Mode == client.SSEC {
+		return Snapshot{}, fmt.Errorf("volume %s uses sse=c, its objects can't be copied without the customer key", name)
+	}
+
+	snap := Snapshot{
+		Bucket:  createName(snapshotPrefix),
+		Created: time.Now().UTC(),
+	}
+	if err := c.Client.MakeBucket(snap.Bucket, bucketRegion(&c)); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to create snapshot bucket: %s", err)
+	}
+
+	glog.V(0).Infof("Creating snapshot %s of volume %s", snap.Bucket, name)
+	c.BucketName = snap.Bucket
+	if err := copySnapshot(&c, source, &snap); err != nil {
+		glog.Warningf("Snapshot %s of volume %s failed: %s", snap.Bucket, name, err)
+		if err := c.PurgeBucket(snap.Bucket); err != nil {
+			glog.Warningf("Failed to remove bucket of failed snapshot %s: %s", snap.Bucket, err)
+		}
+		return Snapshot{}, err
+	}
+
+	d.m.Lock()
+	defer d.m.Unlock()
+	if v, exists := d.volumes[name]; exists {
+		v.snapshots = append(v.snapshots, snap)
+		if err := d.saveState(); err != nil {
+			glog.Warningf("Failed to save state after snapshot %s of volume %s: %s", snap.Bucket, name, err)
+		}
+	}
+	return snap, nil
+}
+
+// copySnapshot sets the default encryption of the snapshot bucket of c, then
+// copies source into it and records its usage in snap.
+func copySnapshot(c *client.MinioClient, source string, snap *Snapshot) error {
+	if c.Encryption != nil {
+		if err := c.SetBucketEncryption(snap.Bucket, c.Encryption); err != nil {
+			return fmt.Errorf("failed to set default encryption: %s", err)
+		}
+	}
+	if err := c.CopyPrefix(source, "", 0, nil); err != nil {
+		return err
+	}
+	var err error
+	snap.Bytes, snap.Objects, err = c.BucketUsage(snap.Bucket)
+	return err
+}
+
+// Snapshots returns the snapshots taken of a volume, oldest first.
+func (d *MinioDriver) Snapshots(name string) ([]Snapshot, error) {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	v, exists := d.volumes[name]
+	if !exists {
+		return nil, newErrVolNotFound(name)
+	}
+	snaps := make([]Snapshot, len(v.snapshots))
+	copy(snaps, v.snapshots)
+	return snaps, nil
+}
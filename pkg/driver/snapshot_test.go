@@ -0,0 +1,64 @@
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+func TestCreateSnapshotFailed(t *testing.T) {
+	var (
+		m       sync.Mutex
+		removed []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, location := r.URL.Query()["location"]
+		switch {
+		case location:
+			w.Write([]byte(`<LocationConstraint>us-east-1</LocationConstraint>`))
+		case r.Method == "GET" && r.URL.Path == "/data-1/":
+			w.Write([]byte(`<ListBucketResult><Name>data-1</Name><IsTruncated>false</IsTruncated>` +
+				`<Contents><Key>a</Key><Size>1</Size></Contents></ListBucketResult>`))
+		case r.Method == "GET":
+			w.Write([]byte(`<ListBucketResult><IsTruncated>false</IsTruncated></ListBucketResult>`))
+		case r.Method == "PUT" && r.Header.Get("X-Amz-Copy-Source") != "":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+		case r.Method == "DELETE":
+			m.Lock()
+			removed = append(removed, strings.Trim(r.URL.Path, "/"))
+			m.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := client.NewMinioClient(strings.TrimPrefix(ts.URL, "http://"), "access", "secret", "data-1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewMinioDriver(nil, false)
+	v := newVolume("miniovol-1", "/mnt/miniovol-1", "data-1")
+	v.c = c
+	d.volumes["test"] = v
+
+	if _, err := d.CreateSnapshot("test"); err == nil {
+		t.Fatalf("Expected the snapshot to fail")
+	}
+	if len(removed) != 1 || !strings.HasPrefix(removed[0], snapshotPrefix) {
+		t.Errorf("Expected the snapshot bucket to be removed, got %v", removed)
+	}
+	if len(v.snapshots) != 0 {
+		t.Errorf("Expected no snapshot to be recorded, got %#v", v.snapshots)
+	}
+
+	v.c.Encryption = &client.Encryption{Mode: client.SSEC, CustomerKey: make([]byte, 32)}
+	removed = nil
+	if _, err := d.CreateSnapshot("test"); err == nil || !strings.Contains(err.Error(), "sse=c") {
+		t.Errorf("Expected snapshots of SSE-C volumes to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+// stateVersion is the version of the state file format.
+const stateVersion = 1
+
+// registryState is the registry of the driver as it is persisted in the state
+// file, so that volumes and their mounts are adopted again on restart.
+type registryState struct {
+	Version int               `json:"version"`
+	Volumes []volumeState     `json:"volumes"`
+	Clones  map[string]string `json:"clones,omitempty"`
+}
+
+// volumeState is the persisted state of a volume.
+type volumeState struct {
+	Name         string     `json:"name"`
+	Volume       string     `json:"volume"`
+	Mountpoint   string     `json:"mountpoint"`
+	Bucket       string     `json:"bucket"`
+	MountIDs     []string   `json:"mountIDs,omitempty"`
+	Server       string     `json:"server"`
+	AccessKey    string     `json:"accessKey"`
+	SecretKey    string     `json:"secretKey"`
+	Secure       bool       `json:"secure"`
+	Region       string     `json:"region,omitempty"`
+	Lookup       string     `json:"lookup,omitempty"`
+	Signature    string     `json:"signatureVersion,omitempty"`
+	Failover     string     `json:"failover,omitempty"`
+	SizeLimit    int64      `json:"sizeLimit,omitempty"`
+	ExpireDays   int        `json:"expireDays,omitempty"`
+	ExpirePrefix string     `json:"expirePrefix,omitempty"`
+	Versioning   string     `json:"versioning,omitempty"`
+	ObjectLock   string     `json:"objectLock,omitempty"`
+	SSE          string     `json:"sse,omitempty"`
+	Snapshots    []Snapshot `json:"snapshots,omitempty"`
+	Profile      string     `json:"profile,omitempty"`
+	ReplicateTo  string     `json:"replicateTo,omitempty"`
+
+	ReplicaCreated     bool      `json:"replicaCreated,omitempty"`
+	SSEKMSKeyID        string    `json:"sseKmsKeyId,omitempty"`
+	SSECustomerKeyFile string    `json:"sseCustomerKeyFile,omitempty"`
+	SecretKeyFile      string    `json:"secretKeyFile,omitempty"`
+	CredentialsUpdated time.Time `json:"credentialsUpdated"`
+	RemountPending     bool      `json:"remountPending,omitempty"`
+	Endpoint           string    `json:"endpoint,omitempty"`
+}
+
+// SetStateFile sets the path of the file the registry of the driver is
+// persisted to. An empty path disables persistence.
+func (d *MinioDriver) SetStateFile(path string) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	d.statePath = path
+}
+
+// SaveState persists the registry of the driver to its state file.
+func (d *MinioDriver) SaveState() error {
+	d.m.RLock()
+	defer d.m.RUnlock()
+
+	return d.saveState()
+}
+
+// saveState persists the registry of the driver. The caller must hold the
+// driver lock. The file is replaced atomically and only readable by root,
+// since it holds the credentials of the volumes.
+func (d *MinioDriver) saveState() error {
+	if d.statePath == "" {
+		return nil
+	}
+
+	state := registryState{Version: stateVersion, Clones: d.clones}
+	for name, v := range d.volumes {
+		state.Volumes = append(state.Volumes, newVolumeState(name, v))
+	}
+	for name, vs := range d.broken {
+		if _, exists := d.volumes[name]; !exists {
+			state.Volumes = append(state.Volumes, vs)
+		}
+	}
+	sort.Sort(byVolumeName(state.Volumes))
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(d.statePath)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	fh, err := ioutil.TempFile(dir, filepath.Base(d.statePath)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fh.Name())
+	if _, err := fh.Write(data); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := fh.Sync(); err != nil {
+		fh.Close()
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	return os.Rename(fh.Name(), d.statePath)
+}
+
+// LoadState restores the registry of the driver from its state file, if it
+// exists. Volumes are restored with their mount IDs, Reconcile can be used
+// afterwards to mount again the volumes that are in use. A volume that can't
+// be restored, like one whose SSE-C key file is missing, is logged and left
+// out, it stays in the state file so that a later start can restore it. The
+// replication of the volumes is resumed without holding the driver lock.
+func (d *MinioDriver) LoadState() error {
+	replicated, err := d.loadState()
+	if err != nil {
+		return err
+	}
+	d.resumeReplication(replicated)
+	return nil
+}
+
+// loadState restores the registry of the driver and returns the restored
+// volumes that are replicated.
+func (d *MinioDriver) loadState() (map[string]*minioVolume, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if d.statePath == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(d.statePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	state := registryState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %s", d.statePath, err)
+	}
+	if state.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported version %d of state %s", state.Version, d.statePath)
+	}
+
+	replicated := make(map[string]*minioVolume)
+	for _, vs := range state.Volumes {
+		v, err := vs.volume()
+		if err != nil {
+			glog.Errorf("Failed to restore volume %s, it's unavailable until the plugin restarts: %s", vs.Name, err)
+			d.broken[vs.Name] = vs
+			continue
+		}
+		d.volumes[vs.Name] = v
+		if v.replicateTo != "" {
+			replicated[vs.Name] = v
+		}
+	}
+	for name, bucket := range state.Clones {
+		d.clones[name] = bucket
+	}
+	glog.V(0).Infof("Restored %d volumes from %s", len(state.Volumes)-len(d.broken), d.statePath)
+	return replicated, nil
+}
+
+func newVolumeState(name string, v *minioVolume) volumeState {
+	ids := make([]string, 0, len(v.mounts))
+	for id := range v.mounts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var kmsKeyID string
+	if v.c.Encryption != nil {
+		kmsKeyID = v.c.Encryption.KMSKeyID
+	}
+	return volumeState{
+		Name:         name,
+		Volume:       v.name,
+		Mountpoint:   v.mountpoint,
+		Bucket:       v.bucketName,
+		MountIDs:     ids,
+		Server:       v.c.ServerURI,
+		AccessKey:    v.c.AccesKeyID,
+		SecretKey:    v.c.SecretAccessKey,
+		Secure:       v.c.Secure,
+		Region:       v.c.Options.Region,
+		Lookup:       v.c.Options.Lookup,
+		Signature:    v.c.Options.SignatureVersion,
+		Failover:     v.c.Options.Failover,
+		SizeLimit:    v.sizeLimit,
+		ExpireDays:   v.expireDays,
+		ExpirePrefix: v.expirePrefix,
+		Versioning:   v.versioning,
+		ObjectLock:   v.objectLock,
+		SSE:          v.sse,
+		Snapshots:    v.snapshots,
+		Profile:      v.profile,
+		ReplicateTo:  v.replicateTo,
+
+		ReplicaCreated:     v.replicaCreated,
+		SSEKMSKeyID:        kmsKeyID,
+		SSECustomerKeyFile: v.sseCustomerKeyFile,
+		SecretKeyFile:      v.secretKeyFile,
+		CredentialsUpdated: v.credentialsUpdated,
+		RemountPending:     v.remountPending,
+		Endpoint:           v.endpoint,
+	}
+}
+
+// volume returns the volume described by the state. The server side
+// encryption of the volume is set up again, which fails when its SSE-C key
+// file can't be read.
+func (vs volumeState) volume() (*minioVolume, error) {
+	c, err := client.NewMinioClientWithOptions(vs.Server, vs.AccessKey, vs.SecretKey, vs.Bucket, vs.Secure, client.Options{
+		Region:           vs.Region,
+		Lookup:           vs.Lookup,
+		SignatureVersion: vs.Signature,
+		Failover:         vs.Failover,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if vs.SSE != "" {
+		c.Encryption, err = parseEncryption(map[string]string{
+			"sse":                vs.SSE,
+			"sseKmsKeyId":        vs.SSEKMSKeyID,
+			"sseCustomerKeyFile": vs.SSECustomerKeyFile,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	v := newVolume(vs.Volume, vs.Mountpoint, vs.Bucket)
+	for _, id := range vs.MountIDs {
+		v.mounts[id] = struct{}{}
+	}
+	v.c = c
+	v.sizeLimit = vs.SizeLimit
+	v.expireDays = vs.ExpireDays
+	v.expirePrefix = vs.ExpirePrefix
+	v.versioning = vs.Versioning
+	v.objectLock = vs.ObjectLock
+	v.sse = vs.SSE
+	v.sseCustomerKeyFile = vs.SSECustomerKeyFile
+	v.snapshots = vs.Snapshots
+	v.profile = vs.Profile
+	v.replicateTo = vs.ReplicateTo
+	v.replicaCreated = vs.ReplicaCreated
+	v.secretKeyFile = vs.SecretKeyFile
+	v.credentialsUpdated = vs.CredentialsUpdated
+	v.remountPending = vs.RemountPending
+	v.endpoint = vs.Endpoint
+	return v, nil
+}
+
+type byVolumeName []volumeState
+
+func (v byVolumeName) Len() int           { return len(v) }
+func (v byVolumeName) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v byVolumeName) Less(i, j int) bool { return v[i].Name < v[j].Name }
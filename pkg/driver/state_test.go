@@ -0,0 +1,157 @@
+package driver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+func newStateDriver(t *testing.T) (*MinioDriver, string) {
+	dir, err := ioutil.TempDir("", "miniovol-state-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewMinioDriver(nil, false)
+	d.SetStateFile(filepath.Join(dir, "state.json"))
+	return d, dir
+}
+
+func TestSaveAndLoadState(t *testing.T) {
+	d, dir := newStateDriver(t)
+	defer os.RemoveAll(dir)
+
+	c, err := client.NewMinioClient("minio:9000", "access", "secret", "testbucket", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := newVolume("miniovol-1", "/mnt/miniovol-1", "testbucket")
+	v.c = c
+	v.mounts["abc"] = struct{}{}
+	v.sizeLimit = 1024
+	v.versioning = "Enabled"
+	v.endpoint = "minio:9000"
+	d.volumes["test"] = v
+	d.clones["other"] = "minio-other"
+
+	if err := d.SaveState(); err != nil {
+		t.Fatalf("An error occured while saving the state: %s", err)
+	}
+	fi, err := os.Stat(d.statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected state to be only readable by its owner, got %s", fi.Mode())
+	}
+
+	restored := NewMinioDriver(nil, false)
+	restored.SetStateFile(d.statePath)
+	if err := restored.LoadState(); err != nil {
+		t.Fatalf("An error occured while loading the state: %s", err)
+	}
+	rv, ok := restored.volumes["test"]
+	if !ok {
+		t.Fatalf("Expected volume test to be restored, got %#v", restored.volumes)
+	}
+	if rv.name != v.name || rv.mountpoint != v.mountpoint || rv.bucketName != v.bucketName {
+		t.Errorf("Expected %#v, got %#v", v, rv)
+	}
+	if !reflect.DeepEqual(rv.mounts, v.mounts) || rv.sizeLimit != 1024 || rv.versioning != "Enabled" || rv.endpoint != "minio:9000" {
+		t.Errorf("Expected mounts and settings to be restored, got %#v", rv)
+	}
+	if !rv.c.Matches("minio:9000", "access", "secret", true, client.Options{}) {
+		t.Errorf("Expected the client of the volume to be restored, got %#v", rv.c)
+	}
+	if restored.clones["other"] != "minio-other" {
+		t.Errorf("Expected clones to be restored, got %#v", restored.clones)
+	}
+}
+
+func TestLoadStateMissingFile(t *testing.T) {
+	d, dir := newStateDriver(t)
+	defer os.RemoveAll(dir)
+
+	if err := d.LoadState(); err != nil {
+		t.Errorf("Expected a missing state file to be ignored, got %s", err)
+	}
+	if len(d.volumes) != 0 {
+		t.Errorf("Expected no volumes, got %#v", d.volumes)
+	}
+}
+
+func TestLoadStateVersion(t *testing.T) {
+	d, dir := newStateDriver(t)
+	defer os.RemoveAll(dir)
+
+	ioutil.WriteFile(d.statePath, []byte(`{"version":2}`), 0600)
+	if err := d.LoadState(); err == nil {
+		t.Error("Expected an unknown state version to fail")
+	}
+}
+
+func TestLoadStateEncryption(t *testing.T) {
+	d, dir := newStateDriver(t)
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "sse.key")
+	ioutil.WriteFile(keyFile, bytes.Repeat([]byte("k"), 32), 0600)
+	c, err := client.NewMinioClient("minio:9000", "access", "secret", "testbucket", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Encryption, err = parseEncryption(map[string]string{"sse": "c", "sseCustomerKeyFile": keyFile}); err != nil {
+		t.Fatal(err)
+	}
+	v := newVolume("miniovol-1", "/mnt/miniovol-1", "testbucket")
+	v.c = c
+	v.sse = client.SSEC
+	v.sseCustomerKeyFile = keyFile
+	d.volumes["test"] = v
+	if err := d.SaveState(); err != nil {
+		t.Fatalf("An error occured while saving the state: %s", err)
+	}
+
+	restored := NewMinioDriver(nil, false)
+	restored.SetStateFile(d.statePath)
+	if err := restored.LoadState(); err != nil {
+		t.Fatalf("An error occured while loading the state: %s", err)
+	}
+	if enc := restored.volumes["test"].c.Encryption; enc == nil || enc.Mode != client.SSEC || !bytes.Equal(enc.CustomerKey, c.Encryption.CustomerKey) {
+		t.Errorf("Expected the SSE-C key of the volume to be restored, got %#v", enc)
+	}
+
+	os.Remove(keyFile)
+	plain := *c
+	plain.Encryption = nil
+	pv := newVolume("miniovol-2", "/mnt/miniovol-2", "plainbucket")
+	pv.c = &plain
+	d.volumes["plain"] = pv
+	if err := d.SaveState(); err != nil {
+		t.Fatalf("An error occured while saving the state: %s", err)
+	}
+	restored = NewMinioDriver(nil, false)
+	restored.SetStateFile(d.statePath)
+	if err := restored.LoadState(); err != nil {
+		t.Fatalf("Expected the other volumes to be restored, got %s", err)
+	}
+	if _, ok := restored.volumes["test"]; ok {
+		t.Errorf("Expected a volume without its SSE-C key not to be restored")
+	}
+	if _, ok := restored.volumes["plain"]; !ok {
+		t.Errorf("Expected the other volumes to be restored, got %#v", restored.volumes)
+	}
+	if err := restored.SaveState(); err != nil {
+		t.Fatalf("An error occured while saving the state: %s", err)
+	}
+	ioutil.WriteFile(keyFile, bytes.Repeat([]byte("k"), 32), 0600)
+	restored = NewMinioDriver(nil, false)
+	restored.SetStateFile(d.statePath)
+	if err := restored.LoadState(); err != nil || restored.volumes["test"] == nil {
+		t.Errorf("Expected the volume to be kept in the state until its key is back, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package driver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+// BucketStatusInterval is how long the usage and configuration of a bucket,
+// as reported in the status of its volume, are cached.
+const BucketStatusInterval = time.Minute
+
+// bucketStatus caches what the server reports about the bucket of a volume.
+// Listing a large bucket or waiting for an unreachable server takes a while,
+// so the status is refreshed in the background and Get only reads it.
+type bucketStatus struct {
+	m          sync.Mutex
+	refreshing bool
+	updated    time.Time
+	values     map[string]interface{}
+}
+
+// cached returns the cached status entries and whether they need a refresh,
+// in which case the caller is expected to refresh them.
+func (s *bucketStatus) cached(maxAge time.Duration) (map[string]interface{}, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	refresh := !s.refreshing && (s.updated.IsZero() || time.Since(s.updated) > maxAge)
+	if refresh {
+		s.refreshing = true
+	}
+	values := make(map[string]interface{}, len(s.values)+1)
+	for k, v := range s.values {
+		values[k] = v
+	}
+	if !s.updated.IsZero() {
+		values["statusUpdated"] = s.updated
+	}
+	return values, refresh
+}
+
+// set replaces the cached status entries.
+func (s *bucketStatus) set(values map[string]interface{}) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.values = values
+	s.updated = time.Now().UTC()
+	s.refreshing = false
+}
+
+// refresh fetches the status of bucket with c and caches it.
+func (s *bucketStatus) refresh(c *client.MinioClient, bucket string, usage, config bool) {
+	values := make(map[string]interface{})
+	if usage {
+		size, objects, err := c.BucketUsage(bucket)
+		if err != nil {
+			glog.Warningf("Failed to retrieve usage of bucket %s: %s", bucket, err)
+			values["usageError"] = err.Error()
+		} else {
+			values["size"] = size
+			values["objects"] = objects
+		}
+	}
+	if config {
+		versioning, err := c.BucketVersioning(bucket)
+		if err != nil {
+			glog.Warningf("Failed to retrieve versioning of bucket %s: %s", bucket, err)
+			values["versioningError"] = err.Error()
+		} else {
+			values["versioning"] = versioning
+		}
+
+		mode, days, err := c.BucketRetention(bucket)
+		if err != nil {
+			glog.Warningf("Failed to retrieve object lock of bucket %s: %s", bucket, err)
+			values["objectLockError"] = err.Error()
+		} else if mode != "" {
+			values["objectLock"] = mode
+			values["retentionDays"] = days
+		}
+	}
+	s.set(values)
+}
+
+// addBucketStatus adds the cached usage and configuration of the bucket of a
+// volume to status, and refreshes them in the background when they are
+// missing or too old. The caller must hold the driver lock, for reading at
+// least.
+func addBucketStatus(v *minioVolume, status map[string]interface{}) {
+	usage := v.sizeLimit > 0
+	config := v.versioning != "" || v.objectLock != ""
+	if !usage && !config {
+		return
+	}
+	values, refresh := v.status.cached(BucketStatusInterval)
+	if refresh {
+		go v.status.refresh(v.c, v.bucketName, usage, config)
+	}
+	if len(values) == 0 {
+		status["statusUpdated"] = "pending"
+	}
+	for k, value := range values {
+		status[k] = value
+	}
+}
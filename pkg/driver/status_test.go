@@ -0,0 +1,55 @@
+package driver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+func TestVolumeStatusCached(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(`{"bucketsUsageInfo": {"data": {"size": 2048, "objectsCount": 3}}}`))
+	}))
+	defer ts.Close()
+	c, err := client.NewMinioClient(strings.TrimPrefix(ts.URL, "http://"), "access", "secret", "data", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewMinioDriver(nil, false)
+	v := newVolume("vol", "/mnt/vol", "data")
+	v.c = c
+	v.sizeLimit = 1 << 20
+	d.volumes["vol"] = v
+
+	got := make(chan map[string]interface{}, 1)
+	go func() {
+		d.m.RLock()
+		defer d.m.RUnlock()
+		got <- d.volumeStatus(v)
+	}()
+	select {
+	case status := <-got:
+		if status["statusUpdated"] != "pending" || status["sizeLimit"] != int64(1<<20) {
+			t.Errorf("Expected a pending status, got %v", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the status not to wait for the server")
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status := d.volumeStatus(v); status["size"] == int64(2048) && status["objects"] == int64(3) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("Expected the usage to be refreshed, got %v", d.volumeStatus(v))
+}
@@ -0,0 +1,43 @@
+package driver
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
+)
+
+// StaleUploadAge is how old a multipart upload left in the bucket of a volume
+// must be to be aborted. Younger ones may still be written by another host
+// sharing the bucket.
+const StaleUploadAge = 24 * time.Hour
+
+// AbortStaleUploads aborts the multipart uploads older than olderThan in the
+// buckets of all the volumes, which writes interrupted by a crash or a
+// killed container leave behind. The driver isn't locked while the buckets
+// are listed.
+func (d *MinioDriver) AbortStaleUploads(olderThan time.Duration) {
+	d.m.RLock()
+	clients := make(map[string]*client.MinioClient, len(d.volumes))
+	for name, v := range d.volumes {
+		clients[name] = v.c
+	}
+	d.m.RUnlock()
+
+	for name, c := range clients {
+		abortStaleUploads(name, c, olderThan)
+	}
+}
+
+// abortStaleUploads aborts the multipart uploads older than olderThan in the
+// bucket of the volume name.
+func abortStaleUploads(name string, c *client.MinioClient, olderThan time.Duration) {
+	aborted, err := c.AbortStaleUploads(olderThan)
+	if err != nil {
+		glog.Warningf("Failed to abort stale uploads of volume %s: %s", name, err)
+	}
+	if aborted > 0 {
+		glog.V(0).Infof("Aborted %d stale uploads of volume %s", aborted, name)
+	}
+}
@@ -2,11 +2,18 @@ package driver
 
 import (
 	"fmt"
+	"io/ioutil"
+	"math"
 	"math/rand"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/docker/go-plugins-helpers/volume"
 	"github.com/golang/glog"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
 )
 
 const (
@@ -16,6 +23,10 @@ const (
 	volumePrefix = "miniovol-"
 	bucketPrefix = "miniobucket-"
 	location     = "us-east-1"
+
+	// cloneProgressInterval is the number of objects after which the progress
+	// of a clone is logged.
+	cloneProgressInterval = 100
 )
 
 type minfsCfg struct {
@@ -61,7 +72,7 @@ func newErrVolNotFound(v string) error {
 // This is necessary for minfs to autheticate with the Minio instance.
 // NOTE: move this to the driver to streamline testing?
 // NOTE: if the API is correct, it should be possible to do this via env vars.
-func provisionConfig(m *MinioDriver) error {
+func provisionConfig(accessKey, secretKey string) error {
 	if _, err := os.Stat(cfgDir); os.IsNotExist(err) {
 		if err = os.MkdirAll(cfgDir, 0755); err != nil {
 			glog.V(1).Infof("Error while creating MinFS config dir: %s", err)
@@ -74,8 +85,8 @@ func provisionConfig(m *MinioDriver) error {
 
 	details := fmt.Sprintf(`{"version":"%s","accessKey":"%s","secretKey":"%s"}`,
 		vers,
-		m.accessKey,
-		m.secretKey,
+		accessKey,
+		secretKey,
 	)
 
 	fh, err := os.Create(cfgFile)
@@ -112,6 +123,235 @@ func checkParam(param string, opts map[string]string) (string, error) {
 	return stringParam, nil
 }
 
+// bucketConfigParams are the options that are only applied to buckets created
+// by the plugin.
+var bucketConfigParams = []string{"expireDays", "versioning", "objectLock"}
+
+// unsupportedParams are the options that need something the plugin doesn't
+// have yet, along with the reason they are refused. Volumes are only mounted
+// with minfs, which writes through to the bucket as is.
+var unsupportedParams = map[string]string{
+	"encrypt":              "client side encryption needs a native mount backend, use sse for encryption at rest",
+	"sts":                  "temporary credentials need a native mount backend, minfs only takes static keys",
+	"webIdentityTokenFile": "temporary credentials need a native mount backend, minfs only takes static keys",
+	"scopedCredentials":    "the MinIO admin API encrypts new service accounts with argon2 and sio, which the plugin doesn't ship",
+	"maxObjects":           "bucket quotas only limit the size of a bucket, use sizeLimit",
+	"cacheInvalidation":    "invalidating mount caches on bucket notifications needs a native mount backend, minfs keeps its own caches",
+	"posixMetadata":        "storing modes, owners, symlinks and empty directories in objects needs a native mount backend, minfs drops them",
+	"partSize":             "streaming multipart uploads need a native mount backend, minfs uploads files on close",
+	"uploadConcurrency":    "streaming multipart uploads need a native mount backend, minfs uploads files on close",
+	"readAhead":            "range reads with read-ahead need a native mount backend, minfs reads objects through its own cache",
+	"readAheadWindow":      "range reads with read-ahead need a native mount backend, minfs reads objects through its own cache",
+	"prefetchWorkers":      "range reads with read-ahead need a native mount backend, minfs reads objects through its own cache",
+}
+
+// checkUnsupported returns an error for the first option, in alphabetical
+// order, that the plugin can't honor.
+func checkUnsupported(opts map[string]string) error {
+	params := make([]string, 0, len(unsupportedParams))
+	for param := range unsupportedParams {
+		params = append(params, param)
+	}
+	sort.Strings(params)
+	for _, param := range params {
+		if _, exists := opts[param]; exists {
+			return fmt.Errorf("%s option is not supported: %s", param, unsupportedParams[param])
+		}
+	}
+	return nil
+}
+
+// backendMinfs is the only mount backend of the plugin.
+const backendMinfs = "minfs"
+
+// checkBackendName returns an error when backend names a mount backend the
+// plugin doesn't have. An empty backend selects minfs.
+func checkBackendName(backend string) error {
+	if backend != "" && backend != backendMinfs {
+		return fmt.Errorf("unknown backend %s, only %s is available", backend, backendMinfs)
+	}
+	return nil
+}
+
+// sizeUnits maps the suffixes accepted by parseSize to their multiplier.
+var sizeUnits = map[string]int64{
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+// parseSize parses a size in bytes with an optional K, M, G or T suffix.
+func parseSize(size string) (int64, error) {
+	if size == "" {
+		return 0, fmt.Errorf("invalid size %s", size)
+	}
+	number := size
+	multiplier := int64(1)
+	if unit, ok := sizeUnits[strings.ToUpper(size[len(size)-1:])]; ok {
+		multiplier = unit
+		number = size[:len(size)-1]
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %s", size)
+	}
+	if n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("size %s is too large", size)
+	}
+	return n * multiplier, nil
+}
+
+// parseQuota returns the sizeLimit option, zero if it's not set.
+func parseQuota(opts map[string]string) (int64, error) {
+	var sizeLimit int64
+	if size, err := checkParam("sizeLimit", opts); err == nil {
+		if sizeLimit, err = parseSize(size); err != nil {
+			return 0, fmt.Errorf("invalid sizeLimit option: %s", err)
+		}
+	}
+	return sizeLimit, nil
+}
+
+// parseExpiration returns the expireDays and expirePrefix options. Zero days
+// means that no expiration was requested.
+func parseExpiration(opts map[string]string) (int, string, error) {
+	prefix, _ := checkParam("expirePrefix", opts)
+	days, err := checkParam("expireDays", opts)
+	if err != nil {
+		if prefix != "" {
+			return 0, "", fmt.Errorf("expirePrefix option requires expireDays")
+		}
+		return 0, "", nil
+	}
+	n, err := strconv.Atoi(days)
+	if err != nil || n <= 0 {
+		return 0, "", fmt.Errorf("invalid expireDays option %s", days)
+	}
+	return n, prefix, nil
+}
+
+// parseVersioning returns the versioning status and the object lock mode and
+// retention days requested through the options, in the form expected by S3.
+// Object lock always enables versioning, so suspending it is refused.
+func parseVersioning(opts map[string]string) (string, string, int, error) {
+	var versioning, objectLock string
+	if v, err := checkParam("versioning", opts); err == nil {
+		switch v {
+		case "enabled":
+			versioning = "Enabled"
+		case "suspended":
+			versioning = "Suspended"
+		default:
+			return "", "", 0, fmt.Errorf("invalid versioning option %s", v)
+		}
+	}
+
+	lock, err := checkParam("objectLock", opts)
+	if err != nil {
+		if _, err := checkParam("retentionDays", opts); err == nil {
+			return "", "", 0, fmt.Errorf("retentionDays option requires objectLock")
+		}
+		return versioning, "", 0, nil
+	}
+	switch lock {
+	case "governance", "compliance":
+		objectLock = strings.ToUpper(lock)
+	default:
+		return "", "", 0, fmt.Errorf("invalid objectLock option %s", lock)
+	}
+	if versioning == "Suspended" {
+		return "", "", 0, fmt.Errorf("objectLock option requires versioning to be enabled")
+	}
+
+	days, err := checkParam("retentionDays", opts)
+	if err != nil {
+		return "", "", 0, err
+	}
+	retentionDays, err := strconv.Atoi(days)
+	if err != nil || retentionDays <= 0 {
+		return "", "", 0, fmt.Errorf("invalid retentionDays option %s", days)
+	}
+	return "Enabled", objectLock, retentionDays, nil
+}
+
+// parseClientOptions returns the provider options of the client requested
+// through the region, pathStyle and signatureVersion options.
+func parseClientOptions(opts map[string]string) (client.Options, error) {
+	clientOpts := client.Options{}
+	clientOpts.Region, _ = checkParam("region", opts)
+	if pathStyle, err := checkParam("pathStyle", opts); err == nil {
+		path, err := strconv.ParseBool(pathStyle)
+		if err != nil {
+			return clientOpts, fmt.Errorf("invalid pathStyle %s", pathStyle)
+		}
+		clientOpts.Lookup = client.LookupVirtualHost
+		if path {
+			clientOpts.Lookup = client.LookupPath
+		}
+	}
+	if version, err := checkParam("signatureVersion", opts); err == nil {
+		switch strings.ToLower(strings.TrimPrefix(version, "s3")) {
+		case "v2", "2":
+			clientOpts.SignatureVersion = client.SignatureV2
+		case "v4", "4":
+			clientOpts.SignatureVersion = client.SignatureV4
+		default:
+			return clientOpts, fmt.Errorf("invalid signatureVersion %s, must be v2 or v4", version)
+		}
+	}
+	if failover, err := checkParam("failover", opts); err == nil {
+		switch failover {
+		case client.FailoverOrdered, client.FailoverRoundRobin:
+			clientOpts.Failover = failover
+		default:
+			return clientOpts, fmt.Errorf("invalid failover %s, must be %s or %s", failover, client.FailoverOrdered, client.FailoverRoundRobin)
+		}
+	}
+	return clientOpts, nil
+}
+
+// bucketRegion returns the region the buckets of a client are created in.
+func bucketRegion(c *client.MinioClient) string {
+	if c.Options.Region != "" {
+		return c.Options.Region
+	}
+	return location
+}
+
+// parseEncryption returns the server side encryption requested through the
+// sse, sseKmsKeyId and sseCustomerKeyFile options, nil if sse is not set.
+// The SSE-C key file must hold the raw 256 bit key.
+func parseEncryption(opts map[string]string) (*client.Encryption, error) {
+	mode, err := checkParam("sse", opts)
+	if err != nil {
+		return nil, nil
+	}
+	kmsKeyID, _ := checkParam("sseKmsKeyId", opts)
+
+	var customerKey []byte
+	if mode == client.SSEC {
+		keyFile, err := checkParam("sseCustomerKeyFile", opts)
+		if err != nil {
+			return nil, err
+		}
+		if customerKey, err = ioutil.ReadFile(keyFile); err != nil {
+			return nil, fmt.Errorf("failed to read SSE-C key: %s", err)
+		}
+	}
+	return client.NewEncryption(mode, kmsKeyID, customerKey)
+}
+
+// withOption returns a copy of opts with param set to value.
+func withOption(opts map[string]string, param, value string) map[string]string {
+	newOpts := make(map[string]string, len(opts)+1)
+	for k, v := range opts {
+		newOpts[k] = v
+	}
+	newOpts[param] = value
+	return newOpts
+}
+
 func volumeResp(mountPoint, rName string, volumes []*volume.Volume, capabilities volume.Capability, err string) volume.Response {
 	return volume.Response{
 		Err: err,
@@ -1,13 +1,18 @@
 package driver
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/docker/go-plugins-helpers/volume"
+
+	"github.com/cloudflavor/miniovol/pkg/client"
 )
 
 func TestError(t *testing.T) {
@@ -76,3 +81,213 @@ func TestVolumeResp(t *testing.T) {
 func TestNewMinioDriver(t *testing.T) {
 	//client :=
 }
+
+func TestWithOption(t *testing.T) {
+	opts := map[string]string{"server": "localhost:9000"}
+	newOpts := withOption(opts, "bucket", "test")
+	if newOpts["bucket"] != "test" || newOpts["server"] != "localhost:9000" {
+		t.Errorf("Expected bucket and server to be set, got %#v", newOpts)
+	}
+	if _, ok := opts["bucket"]; ok {
+		t.Errorf("Expected original options to stay untouched, got %#v", opts)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"512":  512,
+		"10k":  10 << 10,
+		"100M": 100 << 20,
+		"2G":   2 << 30,
+		"1T":   1 << 40,
+	}
+	for size, expected := range tests {
+		n, err := parseSize(size)
+		if err != nil {
+			t.Errorf("An error occured while parsing %s: %s", size, err)
+		}
+		if n != expected {
+			t.Errorf("Expected %s to be %d bytes, got %d", size, expected, n)
+		}
+	}
+
+	for _, size := range []string{"", "G", "-1", "10X", "9000000000T"} {
+		if _, err := parseSize(size); err == nil {
+			t.Errorf("Expected parsing %s to fail", size)
+		}
+	}
+}
+
+func TestParseQuota(t *testing.T) {
+	sizeLimit, err := parseQuota(map[string]string{"sizeLimit": "1G"})
+	if err != nil {
+		t.Fatalf("An error occured while parsing the quota: %s", err)
+	}
+	if sizeLimit != 1<<30 {
+		t.Errorf("Expected 1G, got %d", sizeLimit)
+	}
+
+	if _, err := parseQuota(map[string]string{"sizeLimit": "many"}); err == nil {
+		t.Errorf("Expected an invalid sizeLimit to fail")
+	}
+	if err := checkUnsupported(map[string]string{"maxObjects": "1000"}); err == nil {
+		t.Errorf("Expected maxObjects option to be refused")
+	}
+}
+
+func TestParseExpiration(t *testing.T) {
+	days, prefix, err := parseExpiration(map[string]string{"expireDays": "7", "expirePrefix": "tmp/"})
+	if err != nil {
+		t.Fatalf("An error occured while parsing the expiration: %s", err)
+	}
+	if days != 7 || prefix != "tmp/" {
+		t.Errorf("Expected 7 days and tmp/, got %d and %s", days, prefix)
+	}
+
+	if days, _, err := parseExpiration(map[string]string{}); err != nil || days != 0 {
+		t.Errorf("Expected no expiration, got %d days and %v", days, err)
+	}
+	if _, _, err := parseExpiration(map[string]string{"expirePrefix": "tmp/"}); err == nil {
+		t.Errorf("Expected expirePrefix without expireDays to fail")
+	}
+	if _, _, err := parseExpiration(map[string]string{"expireDays": "0"}); err == nil {
+		t.Errorf("Expected expireDays of 0 to fail")
+	}
+}
+
+func TestParseVersioning(t *testing.T) {
+	versioning, lock, days, err := parseVersioning(map[string]string{"objectLock": "compliance", "retentionDays": "30"})
+	if err != nil {
+		t.Fatalf("An error occured while parsing the versioning: %s", err)
+	}
+	if versioning != "Enabled" || lock != "COMPLIANCE" || days != 30 {
+		t.Errorf("Expected Enabled, COMPLIANCE and 30 days, got %s, %s and %d", versioning, lock, days)
+	}
+
+	versioning, lock, _, err = parseVersioning(map[string]string{"versioning": "suspended"})
+	if err != nil || versioning != "Suspended" || lock != "" {
+		t.Errorf("Expected suspended versioning without lock, got %s, %s and %v", versioning, lock, err)
+	}
+
+	invalid := []map[string]string{
+		{"versioning": "on"},
+		{"objectLock": "legal"},
+		{"objectLock": "governance"},
+		{"retentionDays": "30"},
+		{"objectLock": "governance", "retentionDays": "30", "versioning": "suspended"},
+	}
+	for _, opts := range invalid {
+		if _, _, _, err := parseVersioning(opts); err == nil {
+			t.Errorf("Expected %#v to fail", opts)
+		}
+	}
+}
+
+func TestParseEncryption(t *testing.T) {
+	enc, err := parseEncryption(map[string]string{})
+	if err != nil || enc != nil {
+		t.Errorf("Expected no encryption, got %#v and %v", enc, err)
+	}
+
+	enc, err = parseEncryption(map[string]string{"sse": "kms", "sseKmsKeyId": "my-key"})
+	if err != nil {
+		t.Fatalf("An error occured while parsing the encryption: %s", err)
+	}
+	if enc.Mode != client.SSEKMS || enc.KMSKeyID != "my-key" {
+		t.Errorf("Expected SSE-KMS with my-key, got %#v", enc)
+	}
+
+	keyFile, err := ioutil.TempFile("", "miniovol-sse-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(keyFile.Name())
+	keyFile.Write(bytes.Repeat([]byte("k"), 32))
+	keyFile.Close()
+
+	enc, err = parseEncryption(map[string]string{"sse": "c", "sseCustomerKeyFile": keyFile.Name()})
+	if err != nil {
+		t.Fatalf("An error occured while parsing the encryption: %s", err)
+	}
+	if enc.Mode != client.SSEC || len(enc.CustomerKey) != 32 {
+		t.Errorf("Expected SSE-C with a 32 byte key, got %#v", enc)
+	}
+
+	if _, err := parseEncryption(map[string]string{"sse": "c"}); err == nil {
+		t.Errorf("Expected SSE-C without a key file to fail")
+	}
+}
+
+func TestCheckUnsupported(t *testing.T) {
+	if err := checkUnsupported(map[string]string{"server": "localhost:9000"}); err != nil {
+		t.Errorf("Expected supported options to pass, got %s", err)
+	}
+	if err := checkUnsupported(map[string]string{"encrypt": "true"}); err == nil {
+		t.Errorf("Expected encrypt option to be refused")
+	}
+	if err := checkUnsupported(map[string]string{"sts": "assumeRole"}); err == nil {
+		t.Errorf("Expected sts option to be refused")
+	}
+	if err := checkUnsupported(map[string]string{"cacheInvalidation": "notifications"}); err == nil {
+		t.Errorf("Expected cacheInvalidation option to be refused")
+	}
+	if err := checkUnsupported(map[string]string{"posixMetadata": "true"}); err == nil {
+		t.Errorf("Expected posixMetadata option to be refused")
+	}
+	if err := checkUnsupported(map[string]string{"partSize": "64M"}); err == nil {
+		t.Errorf("Expected partSize option to be refused")
+	}
+	if err := checkUnsupported(map[string]string{"readAheadWindow": "8M"}); err == nil {
+		t.Errorf("Expected readAheadWindow option to be refused")
+	}
+	for i := 0; i < 10; i++ {
+		err := checkUnsupported(map[string]string{"sts": "assumeRole", "encrypt": "true", "partSize": "64M"})
+		if err == nil || !strings.HasPrefix(err.Error(), "encrypt option") {
+			t.Fatalf("Expected the first refused option to be reported, got %v", err)
+		}
+	}
+}
+
+func TestParseClientOptions(t *testing.T) {
+	opts, err := parseClientOptions(map[string]string{
+		"region":           "eu-west-1",
+		"pathStyle":        "false",
+		"signatureVersion": "V4",
+		"failover":         "roundRobin",
+	})
+	if err != nil {
+		t.Fatalf("An error occured while parsing the client options: %s", err)
+	}
+	expected := client.Options{
+		Region:           "eu-west-1",
+		Lookup:           client.LookupVirtualHost,
+		SignatureVersion: client.SignatureV4,
+		Failover:         client.FailoverRoundRobin,
+	}
+	if opts != expected {
+		t.Errorf("Expected %#v, got %#v", expected, opts)
+	}
+
+	if opts, err := parseClientOptions(map[string]string{"pathStyle": "true"}); err != nil || opts.Lookup != client.LookupPath {
+		t.Errorf("Expected path style, got %#v and %v", opts, err)
+	}
+	for _, invalid := range []map[string]string{{"pathStyle": "maybe"}, {"signatureVersion": "v3"}, {"failover": "random"}} {
+		if _, err := parseClientOptions(invalid); err == nil {
+			t.Errorf("Expected %v to be refused", invalid)
+		}
+	}
+}
+
+func TestBucketRegion(t *testing.T) {
+	c, err := client.NewMinioClient("localhost:9000", "access", "secret", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region := bucketRegion(c); region != location {
+		t.Errorf("Expected the default location, got %s", region)
+	}
+	c.Options.Region = "eu-west-1"
+	if region := bucketRegion(c); region != "eu-west-1" {
+		t.Errorf("Expected eu-west-1, got %s", region)
+	}
+}